@@ -3,15 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	_ "time/tzdata"
 
 	"mini-ledger/internal/api"
 	"mini-ledger/internal/config"
 	"mini-ledger/internal/db"
+	"mini-ledger/internal/metrics"
 	"mini-ledger/internal/repository"
 	"mini-ledger/internal/service"
+	"mini-ledger/internal/tracing"
 
 	"github.com/go-chi/chi/v5"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/fx"
 )
 
@@ -19,22 +24,56 @@ func main() {
 	fx.New(
 		fx.Provide(
 			config.New,
+			tracing.New,
 			db.New,
+			metrics.New,
 			repository.NewAccountRepository,
 			repository.NewHoldingRepository,
 			repository.NewOrderRepository,
+			repository.NewOrderEventRepository,
+			repository.NewTradeRepository,
+			repository.NewJournalRepository,
+			repository.NewHoldRepository,
+			repository.NewIdempotencyRepository,
+			repository.NewCashMovementRepository,
+			repository.NewTransferRepository,
+			repository.NewAPIKeyRepository,
+			repository.NewAuditLogRepository,
+			repository.NewRateLimitRepository,
 			service.NewTradingService,
+			service.NewAccountService,
+			service.NewCashService,
+			service.NewTransferService,
+			service.NewAuthenticator,
+			service.NewAuditService,
+			service.NewRateLimiter,
+			service.NewExpiryWorker,
 			api.NewHandler,
 			api.NewRouter,
 		),
-		fx.Invoke(startServer),
+		fx.Invoke(startTracing, startServer, startAdminServer, startExpiryWorker),
 	).Run()
 }
 
+// startTracing runs first so that the tracer provider is installed before
+// anything is traced, and, its hook being appended first, is shut down last,
+// flushing the spans of the requests drained on shutdown.
+func startTracing(lc fx.Lifecycle, provider *sdktrace.TracerProvider) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return provider.Shutdown(ctx)
+		},
+	})
+}
+
 func startServer(lc fx.Lifecycle, cfg *config.Config, router *chi.Mux) {
+	// Requests derive their context from baseCtx, which is canceled once
+	// graceful shutdown gives up so that in-flight statements are aborted.
+	baseCtx, cancel := context.WithCancel(context.Background())
 	server := &http.Server{
-		Addr:    ":" + cfg.HTTPPort,
-		Handler: router,
+		Addr:        ":" + cfg.HTTPPort,
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
 
 	lc.Append(fx.Hook{
@@ -49,7 +88,46 @@ func startServer(lc fx.Lifecycle, cfg *config.Config, router *chi.Mux) {
 		},
 		OnStop: func(ctx context.Context) error {
 			fmt.Println("Shutting down server...")
+			defer cancel()
 			return server.Shutdown(ctx)
 		},
 	})
-}
\ No newline at end of file
+}
+
+// startAdminServer serves /metrics on the admin port, apart from the API so
+// that it can stay off the public network.
+func startAdminServer(lc fx.Lifecycle, cfg *config.Config, m *metrics.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	server := &http.Server{
+		Addr:    ":" + cfg.AdminPort,
+		Handler: mux,
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			go func() {
+				fmt.Printf("Starting admin server on port %s\n", cfg.AdminPort)
+				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					fmt.Printf("Admin server error: %v\n", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return server.Shutdown(ctx)
+		},
+	})
+}
+
+func startExpiryWorker(lc fx.Lifecycle, worker *service.ExpiryWorker) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			worker.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return worker.Stop(ctx)
+		},
+	})
+}
@@ -1,26 +1,181 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"mini-ledger/internal/domain"
+	"mini-ledger/internal/metrics"
 	"mini-ledger/internal/service"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type Handler struct {
-	tradingService *service.TradingService
+	tradingService  *service.TradingService
+	accountService  *service.AccountService
+	cashService     *service.CashService
+	transferService *service.TransferService
+	auditService    *service.AuditService
+	metrics         *metrics.Metrics
 }
 
-func NewHandler(tradingService *service.TradingService) *Handler {
+func NewHandler(
+	tradingService *service.TradingService,
+	accountService *service.AccountService,
+	cashService *service.CashService,
+	transferService *service.TransferService,
+	auditService *service.AuditService,
+	metrics *metrics.Metrics,
+) *Handler {
 	return &Handler{
-		tradingService: tradingService,
+		tradingService:  tradingService,
+		accountService:  accountService,
+		cashService:     cashService,
+		transferService: transferService,
+		auditService:    auditService,
+		metrics:         metrics,
 	}
 }
 
+func (h *Handler) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateAccountRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	account, err := h.accountService.CreateAccount(r.Context(), &req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, account, http.StatusCreated)
+}
+
+// ListAccounts lists the caller's accounts.
+func (h *Handler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	h.listAccounts(w, r, domain.PrincipalFromContext(r.Context()).AccountIDs)
+}
+
+// AdminListAccounts lists every account.
+func (h *Handler) AdminListAccounts(w http.ResponseWriter, r *http.Request) {
+	h.listAccounts(w, r, nil)
+}
+
+func (h *Handler) listAccounts(w http.ResponseWriter, r *http.Request, accountIDs []int) {
+	query := r.URL.Query()
+	filter := &domain.AccountFilter{
+		AccountIDs: accountIDs,
+		Status:     domain.AccountStatus(query.Get("status")),
+	}
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		h.writeErrorResponse(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			h.writeErrorResponse(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("cursor"); value != "" {
+		after, err := domain.ParseIDCursor(value)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+		filter.After = after
+	}
+
+	page, err := h.accountService.ListAccounts(r.Context(), filter)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, page, http.StatusOK)
+}
+
+func (h *Handler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeAccount(w, r, accountID) {
+		return
+	}
+
+	account, err := h.accountService.GetAccount(r.Context(), accountID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, account, http.StatusOK)
+}
+
+// AdminGetAccount returns any account.
+func (h *Handler) AdminGetAccount(w http.ResponseWriter, r *http.Request) {
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.accountService.GetAccount(r.Context(), accountID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, account, http.StatusOK)
+}
+
+func (h *Handler) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.changeAccountStatus(w, r, h.accountService.FreezeAccount)
+}
+
+func (h *Handler) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.changeAccountStatus(w, r, h.accountService.UnfreezeAccount)
+}
+
+func (h *Handler) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	h.changeAccountStatus(w, r, h.accountService.CloseAccount)
+}
+
+func (h *Handler) changeAccountStatus(w http.ResponseWriter, r *http.Request, change func(context.Context, int) (*domain.Account, error)) {
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	account, err := change(r.Context(), accountID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, account, http.StatusOK)
+}
+
 func (h *Handler) GetAccountBalance(w http.ResponseWriter, r *http.Request) {
 	accountIDStr := chi.URLParam(r, "accountID")
 	accountID, err := strconv.Atoi(accountIDStr)
@@ -28,8 +183,11 @@ func (h *Handler) GetAccountBalance(w http.ResponseWriter, r *http.Request) {
 		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
 		return
 	}
+	if !h.authorizeAccount(w, r, accountID) {
+		return
+	}
 
-	balance, err := h.tradingService.GetAccountBalance(accountID)
+	balance, err := h.tradingService.GetAccountBalance(r.Context(), accountID)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
@@ -45,8 +203,11 @@ func (h *Handler) GetAccountHoldings(w http.ResponseWriter, r *http.Request) {
 		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
 		return
 	}
+	if !h.authorizeAccount(w, r, accountID) {
+		return
+	}
 
-	holdings, err := h.tradingService.GetAccountHoldings(accountID)
+	holdings, err := h.tradingService.GetAccountHoldings(r.Context(), accountID)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
@@ -55,20 +216,336 @@ func (h *Handler) GetAccountHoldings(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, holdings, http.StatusOK)
 }
 
+func (h *Handler) GetAccountTrades(w http.ResponseWriter, r *http.Request) {
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeAccount(w, r, accountID) {
+		return
+	}
+
+	trades, err := h.tradingService.GetAccountTrades(r.Context(), accountID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, trades, http.StatusOK)
+}
+
+func (h *Handler) ListAccountOrders(w http.ResponseWriter, r *http.Request) {
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeAccount(w, r, accountID) {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := &domain.OrderFilter{
+		AccountID: accountID,
+		Status:    domain.OrderStatus(query.Get("status")),
+		Direction: domain.Direction(query.Get("direction")),
+		StockCode: query.Get("stock_code"),
+	}
+
+	if filter.Status != "" && !filter.Status.IsValid() {
+		h.writeErrorResponse(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	if filter.Direction != "" && !filter.Direction.IsValid() {
+		h.writeErrorResponse(w, "invalid direction", http.StatusBadRequest)
+		return
+	}
+	if value := query.Get("created_from"); value != "" {
+		createdFrom, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.writeErrorResponse(w, "invalid created_from", http.StatusBadRequest)
+			return
+		}
+		filter.CreatedFrom = &createdFrom
+	}
+	if value := query.Get("created_to"); value != "" {
+		createdTo, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.writeErrorResponse(w, "invalid created_to", http.StatusBadRequest)
+			return
+		}
+		filter.CreatedTo = &createdTo
+	}
+	if value := query.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil || filter.Limit <= 0 {
+			h.writeErrorResponse(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("cursor"); value != "" {
+		filter.After, err = domain.ParseOrderCursor(value)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+	}
+
+	page, err := h.tradingService.ListAccountOrders(r.Context(), filter)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, page, http.StatusOK)
+}
+
+func (h *Handler) Deposit(w http.ResponseWriter, r *http.Request) {
+	h.createCashMovement(w, r, h.cashService.Deposit)
+}
+
+func (h *Handler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	h.createCashMovement(w, r, h.cashService.Withdraw)
+}
+
+func (h *Handler) createCashMovement(w http.ResponseWriter, r *http.Request, create func(context.Context, int, *domain.CashMovementRequest) (*domain.CashMovementResult, error)) {
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeAccount(w, r, accountID) {
+		return
+	}
+
+	var req domain.CashMovementRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	result, err := create(r.Context(), accountID, &req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	if result.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+		h.writeJSONResponse(w, result.Movement, http.StatusOK)
+		return
+	}
+	h.writeJSONResponse(w, result.Movement, http.StatusCreated)
+}
+
+func (h *Handler) GetAccountCashMovements(w http.ResponseWriter, r *http.Request) {
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeAccount(w, r, accountID) {
+		return
+	}
+
+	movements, err := h.cashService.GetAccountCashMovements(r.Context(), accountID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, movements, http.StatusOK)
+}
+
+func (h *Handler) GetCashMovement(w http.ResponseWriter, r *http.Request) {
+	movementIDStr := chi.URLParam(r, "movementID")
+	movementID, err := strconv.Atoi(movementIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid cash movement ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeCashMovement(w, r, movementID) {
+		return
+	}
+
+	movement, err := h.cashService.GetCashMovement(r.Context(), movementID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, movement, http.StatusOK)
+}
+
+// AdminGetCashMovement returns a cash movement of any account.
+func (h *Handler) AdminGetCashMovement(w http.ResponseWriter, r *http.Request) {
+	movementIDStr := chi.URLParam(r, "movementID")
+	movementID, err := strconv.Atoi(movementIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid cash movement ID", http.StatusBadRequest)
+		return
+	}
+
+	movement, err := h.cashService.GetCashMovement(r.Context(), movementID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, movement, http.StatusOK)
+}
+
+func (h *Handler) CompleteCashMovement(w http.ResponseWriter, r *http.Request) {
+	movementIDStr := chi.URLParam(r, "movementID")
+	movementID, err := strconv.Atoi(movementIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid cash movement ID", http.StatusBadRequest)
+		return
+	}
+
+	movement, err := h.cashService.CompleteCashMovement(r.Context(), movementID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, movement, http.StatusOK)
+}
+
+func (h *Handler) FailCashMovement(w http.ResponseWriter, r *http.Request) {
+	movementIDStr := chi.URLParam(r, "movementID")
+	movementID, err := strconv.Atoi(movementIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid cash movement ID", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.FailCashMovementRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	movement, err := h.cashService.FailCashMovement(r.Context(), movementID, &req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, movement, http.StatusOK)
+}
+
+func (h *Handler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateTransferRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+	if !h.authorizeAccount(w, r, req.FromAccountID) || !h.authorizeAccount(w, r, req.ToAccountID) {
+		return
+	}
+
+	transfer, err := h.transferService.CreateTransfer(r.Context(), &req)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, transfer, http.StatusCreated)
+}
+
+func (h *Handler) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	transferIDStr := chi.URLParam(r, "transferID")
+	transferID, err := strconv.Atoi(transferIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid transfer ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeTransfer(w, r, transferID) {
+		return
+	}
+
+	transfer, err := h.transferService.GetTransfer(r.Context(), transferID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, transfer, http.StatusOK)
+}
+
+func (h *Handler) GetAccountTransfers(w http.ResponseWriter, r *http.Request) {
+	accountIDStr := chi.URLParam(r, "accountID")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid account ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeAccount(w, r, accountID) {
+		return
+	}
+
+	transfers, err := h.transferService.GetAccountTransfers(r.Context(), accountID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, transfers, http.StatusOK)
+}
+
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	// Requests turned away before reaching the service count as rejected
+	// orders too.
 	var req domain.CreateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeErrorResponse(w, "invalid request body", http.StatusBadRequest)
+	if message := h.decodeRequestBody(w, r, &req); message != "" {
+		h.metrics.OrderRejected(message)
+		return
+	}
+	if !domain.PrincipalFromContext(r.Context()).CanAccess(req.AccountID) {
+		h.metrics.OrderRejected(h.handleServiceError(w, domain.ErrForbidden))
+		return
+	}
+
+	result, err := h.tradingService.CreateOrder(r.Context(), &req, r.Header.Get("Idempotency-Key"))
+	if err != nil {
+		h.metrics.OrderRejected(h.handleServiceError(w, err))
+		return
+	}
+
+	if result.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	} else {
+		h.metrics.OrderCreated(string(result.Order.Type))
+	}
+	h.writeJSONResponse(w, result.Order, result.StatusCode)
+}
+
+func (h *Handler) AmendOrder(w http.ResponseWriter, r *http.Request) {
+	orderIDStr := chi.URLParam(r, "orderID")
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid order ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeOrder(w, r, orderID) {
 		return
 	}
 
-	order, err := h.tradingService.CreateOrder(&req)
+	var req domain.AmendOrderRequest
+	if !h.decodeRequest(w, r, &req) {
+		return
+	}
+
+	order, err := h.tradingService.AmendOrder(r.Context(), orderID, &req)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
 	}
 
-	h.writeJSONResponse(w, order, http.StatusCreated)
+	h.writeJSONResponse(w, order, http.StatusOK)
 }
 
 func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
@@ -78,8 +555,11 @@ func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 		h.writeErrorResponse(w, "invalid order ID", http.StatusBadRequest)
 		return
 	}
+	if !h.authorizeOrder(w, r, orderID) {
+		return
+	}
 
-	order, err := h.tradingService.CancelOrder(orderID)
+	order, err := h.tradingService.CancelOrder(r.Context(), orderID)
 	if err != nil {
 		h.handleServiceError(w, err)
 		return
@@ -88,21 +568,234 @@ func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, order, http.StatusOK)
 }
 
-func (h *Handler) handleServiceError(w http.ResponseWriter, err error) {
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	orderIDStr := chi.URLParam(r, "orderID")
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid order ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeOrder(w, r, orderID) {
+		return
+	}
+
+	order, err := h.tradingService.GetOrder(r.Context(), orderID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, order, http.StatusOK)
+}
+
+func (h *Handler) GetOrderEvents(w http.ResponseWriter, r *http.Request) {
+	orderIDStr := chi.URLParam(r, "orderID")
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid order ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeOrder(w, r, orderID) {
+		return
+	}
+
+	events, err := h.tradingService.GetOrderEvents(r.Context(), orderID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, events, http.StatusOK)
+}
+
+func (h *Handler) GetOrderTrades(w http.ResponseWriter, r *http.Request) {
+	orderIDStr := chi.URLParam(r, "orderID")
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil {
+		h.writeErrorResponse(w, "invalid order ID", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeOrder(w, r, orderID) {
+		return
+	}
+
+	trades, err := h.tradingService.GetOrderTrades(r.Context(), orderID)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, trades, http.StatusOK)
+}
+
+func (h *Handler) ReconcileLedger(w http.ResponseWriter, r *http.Request) {
+	reconciliation, err := h.tradingService.ReconcileLedger(r.Context())
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, reconciliation, http.StatusOK)
+}
+
+func (h *Handler) ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &domain.AuditFilter{Actor: query.Get("actor")}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			h.writeErrorResponse(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("cursor"); value != "" {
+		before, err := domain.ParseIDCursor(value)
+		if err != nil {
+			h.handleServiceError(w, err)
+			return
+		}
+		filter.Before = before
+	}
+
+	page, err := h.auditService.ListAuditLog(r.Context(), filter)
+	if err != nil {
+		h.handleServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, page, http.StatusOK)
+}
+
+// authorizeAccount writes 403 and returns false unless the caller may act on
+// the account. Whether an inaccessible account exists is not revealed.
+func (h *Handler) authorizeAccount(w http.ResponseWriter, r *http.Request, accountID int) bool {
+	if domain.PrincipalFromContext(r.Context()).CanAccess(accountID) {
+		return true
+	}
+	h.handleServiceError(w, domain.ErrForbidden)
+	return false
+}
+
+// authorizeOrder authorizes the caller for the account of an order. An order
+// that does not exist is reported as 403 like one of another account, so
+// order IDs cannot be probed.
+func (h *Handler) authorizeOrder(w http.ResponseWriter, r *http.Request, orderID int) bool {
+	order, err := h.tradingService.GetOrder(r.Context(), orderID)
+	if err != nil {
+		h.handleAuthorizeLookupError(w, err, domain.ErrOrderNotFound)
+		return false
+	}
+	return h.authorizeAccount(w, r, order.AccountID)
+}
+
+// authorizeCashMovement authorizes the caller for the account of a cash
+// movement.
+func (h *Handler) authorizeCashMovement(w http.ResponseWriter, r *http.Request, movementID int) bool {
+	movement, err := h.cashService.GetCashMovement(r.Context(), movementID)
+	if err != nil {
+		h.handleAuthorizeLookupError(w, err, domain.ErrCashMovementNotFound)
+		return false
+	}
+	return h.authorizeAccount(w, r, movement.AccountID)
+}
+
+// authorizeTransfer authorizes the caller for a transfer if it may access
+// either side of it.
+func (h *Handler) authorizeTransfer(w http.ResponseWriter, r *http.Request, transferID int) bool {
+	transfer, err := h.transferService.GetTransfer(r.Context(), transferID)
+	if err != nil {
+		h.handleAuthorizeLookupError(w, err, domain.ErrTransferNotFound)
+		return false
+	}
+	if domain.PrincipalFromContext(r.Context()).CanAccess(transfer.ToAccountID) {
+		return true
+	}
+	return h.authorizeAccount(w, r, transfer.FromAccountID)
+}
+
+// handleAuthorizeLookupError writes the error of looking up the resource to
+// authorize, reporting a missing resource as 403.
+func (h *Handler) handleAuthorizeLookupError(w http.ResponseWriter, err, notFound error) {
+	if errors.Is(err, notFound) {
+		err = domain.ErrForbidden
+	}
+	h.handleServiceError(w, err)
+}
+
+// handleServiceError writes the response for an error returned by a service
+// and returns the error message it sent, which CreateOrder also uses as the
+// reason the order was rejected.
+func (h *Handler) handleServiceError(w http.ResponseWriter, err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		h.writeErrorResponse(w, "request timed out", http.StatusGatewayTimeout)
+		return "request timed out"
+	}
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		h.writeValidationError(w, validationErr)
+		return "validation failed"
+	}
+
+	var transitionErr *domain.OrderTransitionError
+	if errors.As(err, &transitionErr) {
+		h.writeErrorResponse(w, transitionErr.Error(), http.StatusConflict)
+		return transitionErr.Error()
+	}
+	var accountTransitionErr *domain.AccountTransitionError
+	if errors.As(err, &accountTransitionErr) {
+		h.writeErrorResponse(w, accountTransitionErr.Error(), http.StatusConflict)
+		return accountTransitionErr.Error()
+	}
+	var cashTransitionErr *domain.CashMovementTransitionError
+	if errors.As(err, &cashTransitionErr) {
+		h.writeErrorResponse(w, cashTransitionErr.Error(), http.StatusConflict)
+		return cashTransitionErr.Error()
+	}
+
+	message, status := "internal server error", http.StatusInternalServerError
 	switch err {
+	case domain.ErrForbidden:
+		message, status = "access to the account is not allowed", http.StatusForbidden
 	case domain.ErrAccountNotFound:
-		h.writeErrorResponse(w, "account not found", http.StatusNotFound)
+		message, status = "account not found", http.StatusNotFound
 	case domain.ErrOrderNotFound:
-		h.writeErrorResponse(w, "order not found", http.StatusNotFound)
+		message, status = "order not found", http.StatusNotFound
 	case domain.ErrInsufficientFunds:
-		h.writeErrorResponse(w, "insufficient funds", http.StatusBadRequest)
+		message, status = "insufficient funds", http.StatusBadRequest
 	case domain.ErrInsufficientHoldingQuantity:
-		h.writeErrorResponse(w, "insufficient holding quantity", http.StatusBadRequest)
+		message, status = "insufficient holding quantity", http.StatusBadRequest
 	case domain.ErrOrderNotCancelable:
-		h.writeErrorResponse(w, "order is not in a cancelable state", http.StatusBadRequest)
-	default:
-		h.writeErrorResponse(w, "internal server error", http.StatusInternalServerError)
+		message, status = "order is not in a cancelable state", http.StatusBadRequest
+	case domain.ErrOrderNotAmendable:
+		message, status = "order is not in an amendable state", http.StatusBadRequest
+	case domain.ErrInvalidIdempotencyKey:
+		message, status = "invalid idempotency key", http.StatusBadRequest
+	case domain.ErrOrderStateConflict:
+		message, status = "order status was changed concurrently", http.StatusConflict
+	case domain.ErrInvalidCursor:
+		message, status = "invalid cursor", http.StatusBadRequest
+	case domain.ErrAccountNotActive:
+		message, status = "account is not active", http.StatusBadRequest
+	case domain.ErrAccountNotEmpty:
+		message, status = "account still has cash, shares or open orders", http.StatusBadRequest
+	case domain.ErrAccountStateConflict:
+		message, status = "account status was changed concurrently", http.StatusConflict
+	case domain.ErrCashMovementNotFound:
+		message, status = "cash movement not found", http.StatusNotFound
+	case domain.ErrCashMovementStateConflict:
+		message, status = "cash movement status was changed concurrently", http.StatusConflict
+	case domain.ErrTransferNotFound:
+		message, status = "transfer not found", http.StatusNotFound
+	case domain.ErrExternalReferenceReused:
+		message, status = "external reference was already used with a different amount", http.StatusUnprocessableEntity
+	case domain.ErrIdempotencyKeyReused:
+		message, status = "idempotency key was already used with a different request", http.StatusUnprocessableEntity
 	}
+	h.writeErrorResponse(w, message, status)
+	return message
 }
 
 func (h *Handler) writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
@@ -115,4 +808,123 @@ func (h *Handler) writeErrorResponse(w http.ResponseWriter, message string, stat
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(domain.ErrorResponse{Error: message})
-}
\ No newline at end of file
+}
+
+func (h *Handler) writeValidationError(w http.ResponseWriter, err *domain.ValidationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(domain.ErrorResponse{Error: "validation failed", Violations: err.Violations})
+}
+
+// maxRequestBodyBytes bounds the JSON request bodies decodeRequest reads.
+const maxRequestBodyBytes = 1 << 20
+
+// decodeRequest decodes a JSON request body of at most maxRequestBodyBytes
+// into req, rejecting fields the request type does not have. It writes the
+// error response and returns false when the body cannot be decoded; a field
+// that is unknown, has the wrong type or holds an invalid amount is reported
+// as a violation of that field.
+func (h *Handler) decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	return h.decodeRequestBody(w, r, req) == ""
+}
+
+// decodeRequestBody is decodeRequest returning the error message it sent, or
+// "" when the body was decoded, which CreateOrder uses as the reason the
+// order was rejected.
+func (h *Handler) decodeRequestBody(w http.ResponseWriter, r *http.Request, req interface{}) string {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.writeErrorResponse(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return "request body too large"
+		}
+		h.writeErrorResponse(w, "invalid request body", http.StatusBadRequest)
+		return "invalid request body"
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		if violation, ok := decodeViolation(err, body, req); ok {
+			h.writeValidationError(w, &domain.ValidationError{Violations: []domain.FieldViolation{violation}})
+			return "validation failed"
+		}
+		h.writeErrorResponse(w, "invalid request body", http.StatusBadRequest)
+		return "invalid request body"
+	}
+	return ""
+}
+
+// decodeViolation returns the violation of the field a decode error is about,
+// when the error names or can be traced to one field.
+func decodeViolation(err error, body []byte, req interface{}) (domain.FieldViolation, bool) {
+	if field, ok := unknownField(err); ok {
+		return domain.FieldViolation{Field: field, Message: "unknown field"}, true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return domain.FieldViolation{Field: typeErr.Field, Message: "must not be a JSON " + typeErr.Value}, true
+	}
+
+	if errors.Is(err, domain.ErrInvalidAmount) {
+		if field, ok := invalidAmountField(body, req); ok {
+			return domain.FieldViolation{
+				Field:   field,
+				Message: fmt.Sprintf("must be an amount with at most %d decimal places", domain.MoneyScale),
+			}, true
+		}
+	}
+	return domain.FieldViolation{}, false
+}
+
+var moneyType = reflect.TypeOf(domain.Money(0))
+
+// invalidAmountField returns the JSON name of the first amount field of req
+// whose value in body is not a valid amount. encoding/json does not say which
+// field an UnmarshalJSON error came from, so the amounts are parsed again one
+// by one.
+func invalidAmountField(body []byte, req interface{}) (string, bool) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(body, &values); err != nil {
+		return "", false
+	}
+
+	t := reflect.TypeOf(req).Elem()
+	if t.Kind() != reflect.Struct {
+		return "", false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type != moneyType && field.Type != reflect.PointerTo(moneyType) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		var amount domain.Money
+		if err := amount.UnmarshalJSON(value); err != nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// unknownField returns the name of the field a decoder with
+// DisallowUnknownFields rejected. encoding/json reports it only in the error
+// message.
+func unknownField(err error) (string, bool) {
+	const prefix = "json: unknown field "
+	message := err.Error()
+	if !strings.HasPrefix(message, prefix) {
+		return "", false
+	}
+	field, unquoteErr := strconv.Unquote(strings.TrimPrefix(message, prefix))
+	if unquoteErr != nil {
+		return "", false
+	}
+	return field, true
+}
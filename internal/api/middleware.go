@@ -0,0 +1,295 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mini-ledger/internal/domain"
+	"mini-ledger/internal/metrics"
+	"mini-ledger/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withTimeout puts a deadline on the request context. Services and
+// repositories pass that context down to every SQL statement, so a statement
+// still running when the deadline passes or the client disconnects is
+// canceled in CockroachDB.
+func withTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// traceRequests starts a span for every request, continuing the trace of a
+// W3C traceparent header, and names it after the route pattern the request
+// matched once it has been routed.
+func traceRequests(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if route := chi.RouteContext(r.Context()).RoutePattern(); route != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+	})
+	return otelhttp.NewHandler(named, "HTTP request")
+}
+
+// logRequests logs every request like middleware.Logger, followed by the ID
+// of its trace.
+func logRequests(next http.Handler) http.Handler {
+	return middleware.RequestLogger(&traceLogFormatter{
+		logger: log.New(os.Stdout, "", log.LstdFlags),
+	})(next)
+}
+
+type traceLogFormatter struct {
+	logger *log.Logger
+}
+
+func (f *traceLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	logger := &traceLogger{logger: f.logger, traceID: trace.SpanContextFromContext(r.Context()).TraceID()}
+	return (&middleware.DefaultLogFormatter{Logger: logger}).NewLogEntry(r)
+}
+
+// traceLogger appends a trace ID to every line it prints.
+type traceLogger struct {
+	logger  *log.Logger
+	traceID trace.TraceID
+}
+
+func (l *traceLogger) Print(v ...interface{}) {
+	l.logger.Print(fmt.Sprint(v...) + " trace_id=" + l.traceID.String())
+}
+
+// observe records the latency of every request under the route pattern it
+// matched. Requests that matched no route are recorded as "unmatched".
+func observe(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := chi.RouteContext(r.Context()).RoutePattern()
+			if route == "" {
+				route = "unmatched"
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.ObserveHTTPRequest(r.Method, route, status, time.Since(start))
+		})
+	}
+}
+
+// authenticate rejects requests without valid credentials with 401 and puts
+// the principal of the others in the request context. An API key is sent in
+// the X-API-Key header and a JWT as an Authorization bearer token.
+func authenticate(authenticator *service.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var principal *domain.Principal
+			var err error
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				principal, err = authenticator.AuthenticateAPIKey(r.Context(), key)
+			} else if token, ok := bearerToken(r); ok {
+				principal, err = authenticator.AuthenticateJWT(token)
+			} else {
+				err = domain.ErrUnauthenticated
+			}
+
+			if err != nil {
+				status, message := http.StatusUnauthorized, domain.ErrUnauthenticated.Error()
+				if err != domain.ErrUnauthenticated {
+					status, message = http.StatusInternalServerError, "internal server error"
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="mini-ledger"`)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(domain.ErrorResponse{Error: message})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(domain.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// requirePermission rejects with 403 requests from principals none of whose
+// roles grants the permission.
+func requirePermission(permission domain.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !domain.PrincipalFromContext(r.Context()).Can(permission) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(domain.ErrorResponse{Error: "permission " + string(permission) + " is required"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimit rejects with 429 requests for which the caller's bucket or the
+// account's bucket for the budget is empty, with a Retry-After header giving
+// the seconds until both hold a token again. A rejected request takes no
+// token from either bucket. The account's bucket is only charged for accounts
+// the caller may access, so a caller cannot use up another's budget.
+//
+// Requests go through if the limiter fails. This is deliberate: the limits
+// protect capacity, not money, so an outage of the limiter should not also
+// take down order entry and reads.
+func rateLimit(limiter service.RateLimiter, budget service.RateBudget, accountOf func(*http.Request) (int, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := domain.PrincipalFromContext(r.Context())
+			keys := []string{"sub:" + principal.Subject}
+			if principal.APIKeyID != nil {
+				keys[0] = fmt.Sprintf("key:%d", *principal.APIKeyID)
+			}
+			if accountID, ok := accountOf(r); ok && principal.CanAccess(accountID) {
+				keys = append(keys, fmt.Sprintf("account:%d", accountID))
+			}
+
+			allowed, retryAfter, err := limiter.Allow(r.Context(), budget, keys...)
+			if err != nil {
+				fmt.Printf("Rate limit error: %v (%s %s) trace_id=%s\n", err, budget.Name, strings.Join(keys, ", "), trace.SpanContextFromContext(r.Context()).TraceID())
+			} else if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(domain.ErrorResponse{Error: "too many requests"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// audit records every request, including rejected ones, in the audit log with
+// the principal that made it and the status it got. The entry is written
+// before the handler runs and a request whose entry cannot be written is
+// rejected with 500, so no admin action goes unaudited. The status is filled
+// in once the handler has finished, under its own deadline, so a request that
+// timed out still gets one.
+func audit(auditService *service.AuditService, timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := domain.PrincipalFromContext(r.Context())
+			entry := &domain.AuditEntry{
+				Actor:     principal.Subject,
+				APIKeyID:  principal.APIKeyID,
+				Roles:     principal.Roles,
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				RequestID: middleware.GetReqID(r.Context()),
+			}
+			if entry.Roles == nil {
+				entry.Roles = []domain.Role{}
+			}
+
+			if err := auditService.Begin(r.Context(), entry); err != nil {
+				fmt.Printf("Audit log error: %v (%s %s by %s) trace_id=%s\n", err, entry.Method, entry.Path, entry.Actor, trace.SpanContextFromContext(r.Context()).TraceID())
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(domain.ErrorResponse{Error: "internal server error"})
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+			defer cancel()
+			if err := auditService.Finish(ctx, entry, status); err != nil {
+				fmt.Printf("Audit log error: %v (%s %s by %s) trace_id=%s\n", err, entry.Method, entry.Path, entry.Actor, trace.SpanContextFromContext(r.Context()).TraceID())
+			}
+		})
+	}
+}
+
+// requestAccountID returns the account a request acts on, for rate limiting:
+// the account in the URL, the account_id or from_account_id of a JSON request
+// body, or else the caller's account when it may access only one. It never
+// reads the database. The body is read up to maxRequestBodyBytes and put back
+// in front of the rest for the handler, which enforces the limit.
+func requestAccountID(r *http.Request) (int, bool) {
+	if value := chi.URLParam(r, "accountID"); value != "" {
+		accountID, err := strconv.Atoi(value)
+		return accountID, err == nil
+	}
+
+	if r.Body != nil && r.Method == http.MethodPost {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodyBytes))
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if err == nil {
+			var target struct {
+				AccountID     int `json:"account_id"`
+				FromAccountID int `json:"from_account_id"`
+			}
+			if json.Unmarshal(body, &target) == nil {
+				switch {
+				case target.AccountID > 0:
+					return target.AccountID, true
+				case target.FromAccountID > 0:
+					return target.FromAccountID, true
+				}
+			}
+		}
+	}
+
+	principal := domain.PrincipalFromContext(r.Context())
+	if len(principal.AccountIDs) == 1 {
+		return principal.AccountIDs[0], true
+	}
+	return 0, false
+}
+
+// readCloser reads from a reader and closes a closer, so that a body that was
+// partly read and put back still closes the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
@@ -1,24 +1,105 @@
 package api
 
 import (
+	"net/http"
+
+	"mini-ledger/internal/config"
+	"mini-ledger/internal/domain"
+	"mini-ledger/internal/metrics"
+	"mini-ledger/internal/service"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func NewRouter(handler *Handler) *chi.Mux {
+func NewRouter(cfg *config.Config, handler *Handler, authenticator *service.Authenticator, auditService *service.AuditService, limiter service.RateLimiter, metrics *metrics.Metrics) *chi.Mux {
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
+	// The request ID is assigned before logRequests so that its lines carry it.
+	r.Use(traceRequests)
+	r.Use(middleware.RequestID)
+	r.Use(logRequests)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.RequestID)
+	r.Use(observe(metrics))
+
+	// Order entry and reads are throttled per caller and per account, after
+	// the permission check so that rejected requests cost no tokens.
+	throttle := func(budget service.RateBudget) func(http.Handler) http.Handler {
+		if !cfg.RateLimitEnabled {
+			return func(next http.Handler) http.Handler { return next }
+		}
+		return rateLimit(limiter, budget, requestAccountID)
+	}
+	readBudget := service.RateBudget{Name: "read", Rate: cfg.ReadRateLimit, Burst: cfg.ReadRateLimitBurst}
+	orderBudget := service.RateBudget{Name: "order", Rate: cfg.OrderRateLimit, Burst: cfg.OrderRateLimitBurst}
 
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Get("/accounts/{accountID}/balance", handler.GetAccountBalance)
-		r.Get("/accounts/{accountID}/holdings", handler.GetAccountHoldings)
-		r.Post("/orders", handler.CreateOrder)
-		r.Delete("/orders/{orderID}", handler.CancelOrder)
+		r.Use(withTimeout(cfg.StatementTimeout))
+		r.Use(authenticate(authenticator))
+
+		// Customers and traders act on their own accounts only.
+		r.Group(func(r chi.Router) {
+			r.Use(requirePermission(domain.PermissionAccountRead))
+			r.Use(throttle(readBudget))
+			r.Get("/accounts", handler.ListAccounts)
+			r.Get("/accounts/{accountID}", handler.GetAccount)
+			r.Get("/accounts/{accountID}/cash-movements", handler.GetAccountCashMovements)
+			r.Get("/accounts/{accountID}/transfers", handler.GetAccountTransfers)
+			r.Get("/accounts/{accountID}/balance", handler.GetAccountBalance)
+			r.Get("/accounts/{accountID}/holdings", handler.GetAccountHoldings)
+			r.Get("/accounts/{accountID}/trades", handler.GetAccountTrades)
+			r.Get("/accounts/{accountID}/orders", handler.ListAccountOrders)
+			r.Get("/orders/{orderID}", handler.GetOrder)
+			r.Get("/orders/{orderID}/events", handler.GetOrderEvents)
+			r.Get("/orders/{orderID}/trades", handler.GetOrderTrades)
+			r.Get("/cash-movements/{movementID}", handler.GetCashMovement)
+			r.Get("/transfers/{transferID}", handler.GetTransfer)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(requirePermission(domain.PermissionAccountFund))
+			r.Post("/accounts", handler.CreateAccount)
+			r.Post("/accounts/{accountID}/deposits", handler.Deposit)
+			r.Post("/accounts/{accountID}/withdrawals", handler.Withdraw)
+			r.Post("/transfers", handler.CreateTransfer)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(requirePermission(domain.PermissionOrderWrite))
+			r.Use(throttle(orderBudget))
+			r.Post("/orders", handler.CreateOrder)
+			r.Patch("/orders/{orderID}", handler.AmendOrder)
+			r.Delete("/orders/{orderID}", handler.CancelOrder)
+		})
+
+		// Back-office operations act on every account. Every request is
+		// audited, including the ones rejected for lack of permission.
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(audit(auditService, cfg.StatementTimeout))
+
+			r.Group(func(r chi.Router) {
+				r.Use(requirePermission(domain.PermissionAdminRead))
+				r.Get("/accounts", handler.AdminListAccounts)
+				r.Get("/accounts/{accountID}", handler.AdminGetAccount)
+				r.Get("/cash-movements/{movementID}", handler.AdminGetCashMovement)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(requirePermission(domain.PermissionAccountManage))
+				r.Post("/accounts/{accountID}/freeze", handler.FreezeAccount)
+				r.Post("/accounts/{accountID}/unfreeze", handler.UnfreezeAccount)
+				r.Post("/accounts/{accountID}/close", handler.CloseAccount)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(requirePermission(domain.PermissionCashSettle))
+				r.Post("/cash-movements/{movementID}/complete", handler.CompleteCashMovement)
+				r.Post("/cash-movements/{movementID}/fail", handler.FailCashMovement)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(requirePermission(domain.PermissionLedgerAudit))
+				r.Get("/ledger/reconciliation", handler.ReconcileLedger)
+				r.Get("/audit-log", handler.ListAuditLog)
+			})
+		})
 	})
 
 	return r
-}
\ No newline at end of file
+}
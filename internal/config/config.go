@@ -1,12 +1,77 @@
 package config
 
 import (
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/caarlos0/env/v6"
 )
 
 type Config struct {
 	DatabaseURL string `env:"DATABASE_URL" envDefault:"postgresql://root@localhost:26257/mini_ledger?sslmode=disable"`
 	HTTPPort    string `env:"HTTP_PORT" envDefault:"8080"`
+	SellFeeBps  int64  `env:"SELL_FEE_BPS" envDefault:"0"`
+
+	// AdminPort serves /metrics, apart from the API.
+	AdminPort string `env:"ADMIN_PORT" envDefault:"9090"`
+
+	// MarketCollarBps bounds market orders sent without a protection price:
+	// they trade at most this many basis points away from the best opposite
+	// price at the time they arrive.
+	MarketCollarBps int64 `env:"MARKET_COLLAR_BPS" envDefault:"500"`
+
+	// DAY orders expire at DayOrderCutoff in MarketTimezone. The expiry
+	// worker looks for DAY and GTD orders past their expiry every
+	// ExpiryInterval and expires up to ExpiryBatchSize of them per run.
+	MarketTimezone  Location      `env:"MARKET_TIMEZONE" envDefault:"Asia/Seoul"`
+	DayOrderCutoff  ClockTime     `env:"DAY_ORDER_CUTOFF" envDefault:"15:30"`
+	ExpiryInterval  time.Duration `env:"EXPIRY_INTERVAL" envDefault:"10s"`
+	ExpiryBatchSize int           `env:"EXPIRY_BATCH_SIZE" envDefault:"100"`
+
+	// StatementTimeout bounds every API request, and with it every SQL
+	// statement the request runs.
+	StatementTimeout time.Duration `env:"STATEMENT_TIMEOUT" envDefault:"5s"`
+
+	// AutoMigrate applies pending migrations on startup; SeedData also loads
+	// the test data in migrations/seed.
+	AutoMigrate          bool          `env:"AUTO_MIGRATE" envDefault:"true"`
+	SeedData             bool          `env:"SEED_DATA" envDefault:"false"`
+	MigrationLockTimeout time.Duration `env:"MIGRATION_LOCK_TIMEOUT" envDefault:"1m"`
+
+	// Requests authenticate with an API key (X-API-Key) or a JWT
+	// (Authorization: Bearer). JWTs are verified locally with JWTHMACSecret
+	// (HS256) or the RSA public key in JWTRSAPublicKeyFile (RS256); when
+	// JWTIssuer or JWTAudience is set the token must carry it.
+	JWTHMACSecret       string `env:"JWT_HMAC_SECRET"`
+	JWTRSAPublicKeyFile string `env:"JWT_RSA_PUBLIC_KEY_FILE"`
+	JWTIssuer           string `env:"JWT_ISSUER"`
+	JWTAudience         string `env:"JWT_AUDIENCE"`
+
+	// Order entry (placing, amending and canceling orders) and reads each
+	// have a token bucket per API key or JWT subject and one per account:
+	// a bucket holds up to Burst requests and refills at Rate per second.
+	// RateLimitStore is "memory" for buckets local to each replica or
+	// "cockroach" for buckets shared by every replica through the database.
+	RateLimitEnabled    bool    `env:"RATE_LIMIT_ENABLED" envDefault:"true"`
+	RateLimitStore      string  `env:"RATE_LIMIT_STORE" envDefault:"memory"`
+	OrderRateLimit      float64 `env:"ORDER_RATE_LIMIT" envDefault:"20"`
+	OrderRateLimitBurst int     `env:"ORDER_RATE_LIMIT_BURST" envDefault:"50"`
+	ReadRateLimit       float64 `env:"READ_RATE_LIMIT" envDefault:"100"`
+	ReadRateLimitBurst  int     `env:"READ_RATE_LIMIT_BURST" envDefault:"200"`
+
+	// Spans go to TraceExporter: "none", "stdout", "file" (appended to
+	// TraceFile, one JSON span per line) or "otlp" (OTLP over HTTP, set up
+	// with the standard OTEL_EXPORTER_OTLP_* variables). TraceSampleRatio of
+	// new traces are sampled; a trace continued from a traceparent header
+	// keeps the caller's decision.
+	TraceExporter    string  `env:"TRACE_EXPORTER" envDefault:"none"`
+	TraceFile        string  `env:"TRACE_FILE" envDefault:"traces.jsonl"`
+	TraceSampleRatio float64 `env:"TRACE_SAMPLE_RATIO" envDefault:"1"`
+
+	TxMaxAttempts    int           `env:"TX_MAX_ATTEMPTS" envDefault:"5"`
+	TxRetryBaseDelay time.Duration `env:"TX_RETRY_BASE_DELAY" envDefault:"10ms"`
+	TxRetryMaxDelay  time.Duration `env:"TX_RETRY_MAX_DELAY" envDefault:"1s"`
 }
 
 func New() (*Config, error) {
@@ -14,5 +79,61 @@ func New() (*Config, error) {
 	if err := env.Parse(cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
-}
\ No newline at end of file
+}
+
+// validate rejects settings the server cannot run safely with.
+func (c *Config) validate() error {
+	// An empty secret is indistinguishable from no secret once parsed, but
+	// setting the variable to nothing is a mistake worth stopping for.
+	if secret, set := os.LookupEnv("JWT_HMAC_SECRET"); set && secret == "" {
+		return fmt.Errorf("JWT_HMAC_SECRET is set but empty")
+	}
+	// A collar of 100% or more would price a market sell at zero or below.
+	if c.MarketCollarBps <= 0 || c.MarketCollarBps >= 10000 {
+		return fmt.Errorf("MARKET_COLLAR_BPS must be between 1 and 9999, got %d", c.MarketCollarBps)
+	}
+	// With no attempts RunInTx would return without running the transaction.
+	if c.TxMaxAttempts < 1 {
+		return fmt.Errorf("TX_MAX_ATTEMPTS must be at least 1, got %d", c.TxMaxAttempts)
+	}
+	if c.ExpiryInterval <= 0 {
+		return fmt.Errorf("EXPIRY_INTERVAL must be positive, got %s", c.ExpiryInterval)
+	}
+	if c.ExpiryBatchSize <= 0 {
+		return fmt.Errorf("EXPIRY_BATCH_SIZE must be positive, got %d", c.ExpiryBatchSize)
+	}
+	return nil
+}
+
+// Location is a time zone name such as "Asia/Seoul".
+type Location struct {
+	*time.Location
+}
+
+func (l *Location) UnmarshalText(text []byte) error {
+	location, err := time.LoadLocation(string(text))
+	if err != nil {
+		return err
+	}
+	l.Location = location
+	return nil
+}
+
+// ClockTime is a time of day written as "15:04".
+type ClockTime struct {
+	Hour   int
+	Minute int
+}
+
+func (c *ClockTime) UnmarshalText(text []byte) error {
+	parsed, err := time.Parse("15:04", string(text))
+	if err != nil {
+		return fmt.Errorf("invalid time of day %q: %w", text, err)
+	}
+	c.Hour, c.Minute = parsed.Hour(), parsed.Minute()
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import "testing"
+
+func TestNewRejectsInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name, value string
+	}{
+		{name: "TX_MAX_ATTEMPTS", value: "0"},
+		{name: "TX_MAX_ATTEMPTS", value: "-1"},
+		{name: "MARKET_COLLAR_BPS", value: "0"},
+		{name: "MARKET_COLLAR_BPS", value: "10000"},
+		{name: "EXPIRY_INTERVAL", value: "0s"},
+		{name: "EXPIRY_BATCH_SIZE", value: "0"},
+		{name: "JWT_HMAC_SECRET", value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			if _, err := New(); err == nil {
+				t.Errorf("New() with %s=%q succeeded, want an error", tt.name, tt.value)
+			}
+		})
+	}
+}
+
+func TestNewAcceptsDefaults(t *testing.T) {
+	cfg, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if cfg.TxMaxAttempts != 5 {
+		t.Errorf("TxMaxAttempts = %d, want 5", cfg.TxMaxAttempts)
+	}
+}
@@ -1,89 +1,175 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"mini-ledger/internal/config"
+	"mini-ledger/migrations"
 
+	"github.com/XSAM/otelsql"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("mini-ledger/internal/db")
+
 type Database struct {
 	*sqlx.DB
+	retry   RetryPolicy
+	retries atomic.Int64
 }
 
-func New(cfg *config.Config) (*Database, error) {
-	return NewDatabase(cfg.DatabaseURL)
+// RetryPolicy controls how RunInTx retries transactions that CockroachDB
+// aborted with a retryable error.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
 }
 
-func NewDatabase(databaseURL string) (*Database, error) {
-	db, err := sqlx.Connect("postgres", databaseURL)
+func New(cfg *config.Config) (*Database, error) {
+	database, err := NewDatabase(cfg.DatabaseURL, RetryPolicy{
+		MaxAttempts: cfg.TxMaxAttempts,
+		BaseDelay:   cfg.TxRetryBaseDelay,
+		MaxDelay:    cfg.TxRetryMaxDelay,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
 
-	database := &Database{DB: db}
-	if err := database.runMigrations(); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	if cfg.AutoMigrate || cfg.SeedData {
+		migrator, err := NewMigrator(database.DB, migrations.FS, cfg.MigrationLockTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx := context.Background()
+		if cfg.AutoMigrate {
+			if err := migrator.Up(ctx); err != nil {
+				return nil, fmt.Errorf("failed to run migrations: %w", err)
+			}
+		}
+		if cfg.SeedData {
+			if err := migrator.Seed(ctx); err != nil {
+				return nil, fmt.Errorf("failed to seed data: %w", err)
+			}
+		}
 	}
 
 	return database, nil
 }
 
-func (db *Database) runMigrations() error {
-	migrations := []string{
-		`CREATE DATABASE IF NOT EXISTS mini_ledger`,
-		`CREATE TABLE IF NOT EXISTS accounts (
-		    id SERIAL PRIMARY KEY,
-		    account_number STRING NOT NULL UNIQUE,
-		    balance DECIMAL(15,2) NOT NULL,
-		    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-		    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		)`,
-		`CREATE TABLE IF NOT EXISTS holdings (
-		    id SERIAL PRIMARY KEY,
-		    account_id INT NOT NULL REFERENCES accounts(id),
-		    stock_code STRING NOT NULL,
-		    quantity INT NOT NULL,
-		    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-		    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-		    UNIQUE(account_id, stock_code)
-		)`,
-		`CREATE TABLE IF NOT EXISTS orders (
-		    id SERIAL PRIMARY KEY,
-		    account_id INT NOT NULL REFERENCES accounts(id),
-		    stock_code STRING NOT NULL,
-		    type STRING NOT NULL,
-		    direction STRING NOT NULL,
-		    quantity INT NOT NULL,
-		    price DECIMAL(15,2),
-		    filled_quantity INT NOT NULL DEFAULT 0,
-		    status STRING NOT NULL,
-		    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-		    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		)`,
-		`INSERT INTO accounts (id, account_number, balance) VALUES (1, 'AC001', 1000000) ON CONFLICT (id) DO NOTHING`,
-		`INSERT INTO holdings (account_id, stock_code, quantity) VALUES (1, 'STOCK01', 100) ON CONFLICT (account_id, stock_code) DO NOTHING`,
+// NewDatabase connects to CockroachDB through otelsql, so that every
+// statement is traced as a child of the span in its context.
+func NewDatabase(databaseURL string, retry RetryPolicy) (*Database, error) {
+	sqlDB, err := otelsql.Open("postgres", databaseURL,
+		otelsql.WithAttributes(semconv.DBSystemCockroachdb),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	db := sqlx.NewDb(sqlDB, "postgres")
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return &Database{DB: db, retry: retry}, nil
+}
+
+// RunInTx runs fn in a transaction and commits it. When CockroachDB aborts
+// the transaction with a serialization failure (SQLSTATE 40001) or asks the
+// client to restart it, the whole transaction, including fn, is retried with
+// jittered exponential backoff up to the policy's MaxAttempts. fn must
+// therefore be safe to run more than once and must not keep state from a
+// failed attempt.
+//
+// Every attempt is traced as a span of its own; fn gets that span's context,
+// so that its statements are traced under the attempt that ran them.
+func (db *Database) RunInTx(ctx context.Context, fn func(ctx context.Context, tx Querier) error) error {
+	for attempt := 1; ; attempt++ {
+		err := db.runTxOnce(ctx, attempt, fn)
+		if err == nil || !IsRetryable(err) || attempt >= db.retry.MaxAttempts {
+			return err
+		}
+		db.retries.Add(1)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(db.retry.backoff(attempt)):
+		}
 	}
+}
 
-	for i, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
-			return fmt.Errorf("failed to execute migration %d: %w", i+1, err)
+// Retries returns how many times RunInTx has retried a transaction.
+func (db *Database) Retries() int64 {
+	return db.retries.Load()
+}
+
+func (db *Database) runTxOnce(ctx context.Context, attempt int, fn func(ctx context.Context, tx Querier) error) (err error) {
+	ctx, span := tracer.Start(ctx, "db.transaction", trace.WithAttributes(attribute.Int("db.transaction.attempt", attempt)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 		}
+		span.End()
+	}()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsRetryable reports whether err is a transaction conflict that CockroachDB
+// expects the client to resolve by retrying the transaction.
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "40001" {
+		return true
+	}
+	return strings.Contains(err.Error(), "restart transaction")
 }
 
-func (db *Database) BeginTx() (*sqlx.Tx, error) {
-	return db.Beginx()
+// IsUniqueViolation reports whether err is a unique constraint violation,
+// such as a concurrent transaction inserting the same key first.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
 }
 
 type Querier interface {
-	Get(dest interface{}, query string, args ...interface{}) error
-	Select(dest interface{}, query string, args ...interface{}) error
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	NamedExec(query string, arg interface{}) (sql.Result, error)
-}
\ No newline at end of file
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
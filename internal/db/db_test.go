@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// fakeConnector hands out connections whose transactions always begin,
+// commit and roll back, so that RunInTx can run without a database.
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func newFakeDatabase(maxAttempts int) *Database {
+	return &Database{
+		DB:    sqlx.NewDb(sql.OpenDB(fakeConnector{}), "postgres"),
+		retry: RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}
+}
+
+func TestRunInTxRetriesSerializationFailure(t *testing.T) {
+	database := newFakeDatabase(5)
+
+	attempts := 0
+	err := database.RunInTx(context.Background(), func(ctx context.Context, tx Querier) error {
+		attempts++
+		if attempts == 1 {
+			return &pq.Error{Code: "40001", Message: "restart transaction"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTx() error = %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+	if database.Retries() != 1 {
+		t.Errorf("Retries() = %d, want 1", database.Retries())
+	}
+}
+
+func TestRunInTxStopsAtMaxAttempts(t *testing.T) {
+	database := newFakeDatabase(3)
+
+	attempts := 0
+	err := database.RunInTx(context.Background(), func(ctx context.Context, tx Querier) error {
+		attempts++
+		return &pq.Error{Code: "40001"}
+	})
+	if !IsRetryable(err) {
+		t.Fatalf("RunInTx() error = %v, want the serialization failure", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+	if database.Retries() != 2 {
+		t.Errorf("Retries() = %d, want 2", database.Retries())
+	}
+}
+
+func TestRunInTxDoesNotRetryOtherErrors(t *testing.T) {
+	database := newFakeDatabase(5)
+
+	attempts := 0
+	uniqueViolation := &pq.Error{Code: "23505"}
+	err := database.RunInTx(context.Background(), func(ctx context.Context, tx Querier) error {
+		attempts++
+		return uniqueViolation
+	})
+	if !errors.Is(err, uniqueViolation) {
+		t.Fatalf("RunInTx() error = %v, want %v", err, uniqueViolation)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
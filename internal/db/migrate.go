@@ -0,0 +1,540 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrDirtyDatabase    = errors.New("database is dirty")
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	ErrNoDownMigration  = errors.New("migration has no down script")
+	ErrMigrationLocked  = errors.New("timed out waiting for the migration lock")
+	ErrPendingMigration = errors.New("migration has not been applied")
+)
+
+// The holder of the migration lock refreshes its heartbeat every
+// lockHeartbeatInterval for as long as it migrates. A lock whose heartbeat is
+// older than staleLockAfter belongs to a process that died without releasing
+// it, and another process may take it over.
+const (
+	lockHeartbeatInterval = 10 * time.Second
+	staleLockAfter        = time.Minute
+)
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change loaded from NNN_name.up.sql and
+// its optional NNN_name.down.sql.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Version          int
+	Name             string
+	Applied          bool
+	Dirty            bool
+	ChecksumMismatch bool
+	AppliedAt        *time.Time
+}
+
+type appliedMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	Dirty     bool      `db:"dirty"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Migrator applies the migrations found in a file system, recording each
+// applied version with its checksum in schema_migrations. Every operation
+// holds a lock row in schema_migrations_lock, so replicas starting at the
+// same time do not migrate concurrently.
+type Migrator struct {
+	db          *sqlx.DB
+	migrations  []*Migration
+	seeds       fs.FS
+	lockTimeout time.Duration
+	owner       string
+}
+
+// NewMigrator loads the migrations at the root of fsys and the seed scripts
+// in its seed directory.
+func NewMigrator(db *sqlx.DB, fsys fs.FS, lockTimeout time.Duration) (*Migrator, error) {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	seeds, err := fs.Sub(fsys, "seed")
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		seeds:       seeds,
+		lockTimeout: lockTimeout,
+		owner:       fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano()),
+	}, nil
+}
+
+func loadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			migration.Up = string(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []*Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the given number of most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, migration); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Force records every migration up to and including version as cleanly
+// applied and forgets every later one, without running any SQL. It is the way
+// out of a dirty state after the schema was repaired by hand.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	return m.withLock(ctx, func() error {
+		if _, err := m.db.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version > $1`, version); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			query := `UPSERT INTO schema_migrations (version, name, checksum, dirty, applied_at) VALUES ($1, $2, $3, false, NOW())`
+			if _, err := m.db.ExecContext(ctx, query, migration.Version, migration.Name, migration.Checksum); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []*MigrationStatus
+	for _, migration := range m.migrations {
+		status := &MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.Dirty = record.Dirty
+			status.ChecksumMismatch = record.Checksum != migration.Checksum
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, record := range applied {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, &MigrationStatus{
+			Version:   record.Version,
+			Name:      record.Name + " (missing)",
+			Applied:   true,
+			Dirty:     record.Dirty,
+			AppliedAt: &appliedAt,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Seed runs the seed scripts in name order. Seed scripts are not versioned,
+// so each of them must be safe to run again. They are written against the
+// latest schema, so Seed refuses to run while a migration is pending or the
+// database is dirty.
+func (m *Migrator) Seed(ctx context.Context) error {
+	entries, err := fs.ReadDir(m.seeds, ".")
+	if err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verifyUpToDate(applied); err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+				continue
+			}
+			content, err := fs.ReadFile(m.seeds, entry.Name())
+			if err != nil {
+				return err
+			}
+			if err := m.execScript(ctx, string(content)); err != nil {
+				return fmt.Errorf("seed %s: %w", entry.Name(), err)
+			}
+		}
+		return nil
+	})
+}
+
+// apply marks the migration dirty, runs it and marks it clean. A failure
+// leaves the dirty row behind so nothing else runs until it is resolved.
+func (m *Migrator) apply(ctx context.Context, migration *Migration) error {
+	query := `INSERT INTO schema_migrations (version, name, checksum, dirty) VALUES ($1, $2, $3, true)`
+	if _, err := m.db.ExecContext(ctx, query, migration.Version, migration.Name, migration.Checksum); err != nil {
+		return err
+	}
+
+	if err := m.execScript(ctx, migration.Up); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	query = `UPDATE schema_migrations SET dirty = false, applied_at = NOW() WHERE version = $1`
+	_, err := m.db.ExecContext(ctx, query, migration.Version)
+	return err
+}
+
+func (m *Migrator) revert(ctx context.Context, migration *Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, migration.Version, migration.Name)
+	}
+
+	query := `UPDATE schema_migrations SET dirty = true WHERE version = $1`
+	if _, err := m.db.ExecContext(ctx, query, migration.Version); err != nil {
+		return err
+	}
+
+	if err := m.execScript(ctx, migration.Down); err != nil {
+		return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err := m.db.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	return err
+}
+
+// execScript runs the statements of a script one at a time. CockroachDB does
+// not let a transaction use a column or table in the same transaction that
+// is still adding it, so scripts are not wrapped in one transaction.
+func (m *Migrator) execScript(ctx context.Context, script string) error {
+	for _, statement := range splitStatements(script) {
+		if _, err := m.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) verify(applied map[int]*appliedMigration) error {
+	for _, record := range applied {
+		if record.Dirty {
+			return fmt.Errorf("%w at version %d; repair the schema and run force", ErrDirtyDatabase, record.Version)
+		}
+	}
+	for _, migration := range m.migrations {
+		if record, ok := applied[migration.Version]; ok && record.Checksum != migration.Checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// verifyUpToDate is verify that also requires every migration to be applied.
+func (m *Migrator) verifyUpToDate(applied map[int]*appliedMigration) error {
+	if err := m.verify(applied); err != nil {
+		return err
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			return fmt.Errorf("%w: %d_%s; run up first", ErrPendingMigration, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]*appliedMigration, error) {
+	var records []*appliedMigration
+	query := `SELECT version, name, checksum, dirty, applied_at FROM schema_migrations`
+	if err := m.db.SelectContext(ctx, &records, query); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]*appliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+		    version INT PRIMARY KEY,
+		    name STRING NOT NULL,
+		    checksum STRING NOT NULL,
+		    dirty BOOL NOT NULL,
+		    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		    id INT PRIMARY KEY CHECK (id = 1),
+		    owner STRING NOT NULL,
+		    acquired_at TIMESTAMPTZ NOT NULL,
+		    heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`ALTER TABLE schema_migrations_lock ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT NOW()`,
+	}
+	for _, statement := range statements {
+		if _, err := m.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withLock runs fn while holding the single row of schema_migrations_lock,
+// waiting up to lockTimeout for another holder to finish. The lock's
+// heartbeat is refreshed while fn runs, so a long migration keeps it.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(m.lockTimeout)
+	for {
+		acquired, err := m.tryLock(ctx)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return ErrMigrationLocked
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		m.heartbeat(heartbeatCtx)
+	}()
+	defer func() {
+		stopHeartbeat()
+		<-heartbeatDone
+		m.unlock()
+	}()
+
+	return fn()
+}
+
+// tryLock takes the lock row if it is free or its holder stopped refreshing
+// its heartbeat.
+func (m *Migrator) tryLock(ctx context.Context) (bool, error) {
+	query := `INSERT INTO schema_migrations_lock (id, owner, acquired_at, heartbeat_at) VALUES (1, $1, NOW(), NOW())
+			  ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, acquired_at = excluded.acquired_at,
+				  heartbeat_at = excluded.heartbeat_at
+			  WHERE schema_migrations_lock.heartbeat_at < NOW() - $2 * INTERVAL '1 second'`
+	result, err := m.db.ExecContext(ctx, query, m.owner, int(staleLockAfter.Seconds()))
+	if err != nil {
+		if IsRetryable(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	acquired, err := result.RowsAffected()
+	return acquired > 0, err
+}
+
+// heartbeat refreshes the heartbeat of the lock this process holds every
+// lockHeartbeatInterval until ctx is done.
+func (m *Migrator) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(lockHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		query := `UPDATE schema_migrations_lock SET heartbeat_at = NOW() WHERE id = 1 AND owner = $1`
+		result, err := m.db.ExecContext(ctx, query, m.owner)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("Failed to refresh migration lock: %v\n", err)
+			}
+			continue
+		}
+		if refreshed, err := result.RowsAffected(); err == nil && refreshed == 0 {
+			fmt.Printf("Migration lock was taken over by another process\n")
+			return
+		}
+	}
+}
+
+func (m *Migrator) unlock() {
+	query := `DELETE FROM schema_migrations_lock WHERE id = 1 AND owner = $1`
+	if _, err := m.db.ExecContext(context.Background(), query, m.owner); err != nil {
+		fmt.Printf("Failed to release migration lock: %v\n", err)
+	}
+}
+
+// splitStatements splits a SQL script on semicolons that are outside of
+// quotes and comments, dropping empty statements.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	inQuote, inComment := false, false
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case inComment:
+			if c == '\n' {
+				inComment = false
+				current.WriteByte(c)
+			}
+			continue
+		case inQuote:
+			if c == '\'' {
+				inQuote = false
+			}
+		case c == '-' && i+1 < len(script) && script[i+1] == '-':
+			inComment = true
+			continue
+		case c == '\'':
+			inQuote = true
+		case c == ';':
+			if statement := strings.TrimSpace(current.String()); statement != "" {
+				statements = append(statements, statement)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteByte(c)
+	}
+
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
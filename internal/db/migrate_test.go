@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var testMigrations = fstest.MapFS{
+	"001_create_items.up.sql":   {Data: []byte("CREATE TABLE migrate_test_items (id INT PRIMARY KEY, name STRING NOT NULL);")},
+	"001_create_items.down.sql": {Data: []byte("DROP TABLE migrate_test_items;")},
+	"002_add_note.up.sql":       {Data: []byte("ALTER TABLE migrate_test_items ADD COLUMN note STRING;")},
+	"seed/001_items.sql":        {Data: []byte("UPSERT INTO migrate_test_items (id, name, note) VALUES (1, 'first', 'seeded');")},
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- a comment; with a semicolon
+CREATE TABLE t (s STRING DEFAULT 'a;b');
+
+INSERT INTO t VALUES ('it''s'); -- trailing
+;
+SELECT 1`
+	want := []string{
+		"CREATE TABLE t (s STRING DEFAULT 'a;b')",
+		"INSERT INTO t VALUES ('it''s')",
+		"SELECT 1",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestVerifyUpToDate(t *testing.T) {
+	migrator, err := NewMigrator(nil, testMigrations, 0)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	record := func(version int, dirty bool) *appliedMigration {
+		migration := migrator.migrations[version-1]
+		return &appliedMigration{Version: version, Name: migration.Name, Checksum: migration.Checksum, Dirty: dirty}
+	}
+
+	tests := []struct {
+		name    string
+		applied map[int]*appliedMigration
+		want    error
+	}{
+		{name: "up to date", applied: map[int]*appliedMigration{1: record(1, false), 2: record(2, false)}},
+		{name: "pending", applied: map[int]*appliedMigration{1: record(1, false)}, want: ErrPendingMigration},
+		{name: "nothing applied", applied: map[int]*appliedMigration{}, want: ErrPendingMigration},
+		{name: "dirty", applied: map[int]*appliedMigration{1: record(1, false), 2: record(2, true)}, want: ErrDirtyDatabase},
+		{
+			name:    "modified",
+			applied: map[int]*appliedMigration{1: {Version: 1, Name: "create_items", Checksum: "other"}, 2: record(2, false)},
+			want:    ErrChecksumMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := migrator.verifyUpToDate(tt.applied)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("verifyUpToDate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// newTestMigrator connects to the database in MIGRATE_TEST_DATABASE_URL and
+// clears what earlier runs left behind. The tests drop the migration tables,
+// so the URL must point at a scratch database.
+func newTestMigrator(t *testing.T, fsys fstest.MapFS, lockTimeout time.Duration) *Migrator {
+	t.Helper()
+	databaseURL := os.Getenv("MIGRATE_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("MIGRATE_TEST_DATABASE_URL is not set")
+	}
+
+	database, err := sqlx.Connect("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	for _, table := range []string{"migrate_test_items", "schema_migrations", "schema_migrations_lock"} {
+		if _, err := database.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			t.Fatalf("failed to drop %s: %v", table, err)
+		}
+	}
+
+	migrator, err := NewMigrator(database, fsys, lockTimeout)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	return migrator
+}
+
+func TestMigratorSeedRequiresMigrations(t *testing.T) {
+	migrator := newTestMigrator(t, testMigrations, time.Second)
+	ctx := context.Background()
+
+	if err := migrator.Seed(ctx); !errors.Is(err, ErrPendingMigration) {
+		t.Fatalf("Seed() before Up error = %v, want ErrPendingMigration", err)
+	}
+
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if err := migrator.Seed(ctx); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	if err := migrator.Seed(ctx); err != nil {
+		t.Fatalf("Seed() again error = %v", err)
+	}
+}
+
+func TestMigratorDirtyState(t *testing.T) {
+	broken := fstest.MapFS{}
+	for name, file := range testMigrations {
+		broken[name] = file
+	}
+	broken["002_add_note.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE migrate_test_missing ADD COLUMN note STRING;")}
+
+	migrator := newTestMigrator(t, broken, time.Second)
+	ctx := context.Background()
+
+	if err := migrator.Up(ctx); err == nil {
+		t.Fatal("Up() with a failing migration succeeded")
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Status() returned %d migrations, want 2", len(statuses))
+	}
+	if statuses[0].Dirty || !statuses[1].Dirty {
+		t.Fatalf("Status() = %+v, %+v, want 002 alone dirty", statuses[0], statuses[1])
+	}
+
+	if err := migrator.Up(ctx); !errors.Is(err, ErrDirtyDatabase) {
+		t.Errorf("Up() on a dirty database error = %v, want ErrDirtyDatabase", err)
+	}
+	if err := migrator.Down(ctx, 1); !errors.Is(err, ErrDirtyDatabase) {
+		t.Errorf("Down() on a dirty database error = %v, want ErrDirtyDatabase", err)
+	}
+	if err := migrator.Seed(ctx); !errors.Is(err, ErrDirtyDatabase) {
+		t.Errorf("Seed() on a dirty database error = %v, want ErrDirtyDatabase", err)
+	}
+
+	// Forcing back to the last clean version lets the repaired migration run.
+	if err := migrator.Force(ctx, 1); err != nil {
+		t.Fatalf("Force() error = %v", err)
+	}
+	repaired, err := NewMigrator(migrator.db, testMigrations, time.Second)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if err := repaired.Up(ctx); err != nil {
+		t.Fatalf("Up() after Force error = %v", err)
+	}
+	if err := repaired.Seed(ctx); err != nil {
+		t.Fatalf("Seed() after Force error = %v", err)
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	holder := newTestMigrator(t, testMigrations, 0)
+	ctx := context.Background()
+
+	// Ensure the lock table exists before taking the lock by hand.
+	if _, err := holder.Status(ctx); err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	acquired, err := holder.tryLock(ctx)
+	if err != nil || !acquired {
+		t.Fatalf("tryLock() = %v, %v, want the free lock", acquired, err)
+	}
+
+	waiter, err := NewMigrator(holder.db, testMigrations, 0)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if acquired, err := waiter.tryLock(ctx); err != nil || acquired {
+		t.Fatalf("tryLock() of a held lock = %v, %v, want false", acquired, err)
+	}
+	if err := waiter.Up(ctx); !errors.Is(err, ErrMigrationLocked) {
+		t.Fatalf("Up() while locked error = %v, want ErrMigrationLocked", err)
+	}
+
+	// A holder that stopped refreshing its heartbeat loses the lock.
+	query := `UPDATE schema_migrations_lock SET heartbeat_at = NOW() - $1 * INTERVAL '1 second' WHERE id = 1`
+	if _, err := holder.db.ExecContext(ctx, query, int(2*staleLockAfter.Seconds())); err != nil {
+		t.Fatalf("failed to age the lock: %v", err)
+	}
+	if err := waiter.Up(ctx); err != nil {
+		t.Fatalf("Up() over a stale lock error = %v", err)
+	}
+
+	// The waiter released the lock it took over; the old holder cannot
+	// release it again, and the lock is free for the next migrator.
+	holder.unlock()
+	var locks int
+	if err := holder.db.GetContext(ctx, &locks, `SELECT count(*) FROM schema_migrations_lock`); err != nil {
+		t.Fatalf("failed to count locks: %v", err)
+	}
+	if locks != 0 {
+		t.Errorf("locks after Up = %d, want 0", locks)
+	}
+}
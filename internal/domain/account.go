@@ -0,0 +1,96 @@
+package domain
+
+import "fmt"
+
+type AccountStatus string
+
+// A FROZEN account cannot place or amend orders but may still cancel them;
+// an account can only be CLOSED once it holds no cash, no shares and no open
+// orders.
+const (
+	AccountStatusActive AccountStatus = "ACTIVE"
+	AccountStatusFrozen AccountStatus = "FROZEN"
+	AccountStatusClosed AccountStatus = "CLOSED"
+)
+
+// accountTransitions lists, for every status, the statuses an account may
+// move to from it. CLOSED is final.
+var accountTransitions = map[AccountStatus][]AccountStatus{
+	AccountStatusActive: {AccountStatusFrozen, AccountStatusClosed},
+	AccountStatusFrozen: {AccountStatusActive, AccountStatusClosed},
+}
+
+// AccountTransitionError reports an illegal account status transition.
+type AccountTransitionError struct {
+	From AccountStatus
+	To   AccountStatus
+}
+
+func (e *AccountTransitionError) Error() string {
+	return fmt.Sprintf("account cannot move from %s to %s", e.From, e.To)
+}
+
+func (s AccountStatus) IsValid() bool {
+	switch s {
+	case AccountStatusActive, AccountStatusFrozen, AccountStatusClosed:
+		return true
+	}
+	return false
+}
+
+// TransitionTo returns an *AccountTransitionError unless the transition table
+// allows an account to move from s to next.
+func (s AccountStatus) TransitionTo(next AccountStatus) error {
+	for _, allowed := range accountTransitions[s] {
+		if allowed == next {
+			return nil
+		}
+	}
+	return &AccountTransitionError{From: s, To: next}
+}
+
+// accountNumberPrefix starts every generated account number. Accounts created
+// before numbers were generated keep their old numbers.
+const accountNumberPrefix = "AC"
+
+// NewAccountNumber formats a sequence number as an account number: the
+// prefix, the sequence number padded to nine digits and a Luhn check digit,
+// so that a mistyped digit or two swapped neighbouring digits are detected.
+func NewAccountNumber(sequence int64) string {
+	digits := fmt.Sprintf("%09d", sequence)
+	return accountNumberPrefix + digits + string(rune('0'+luhnCheckDigit(digits)))
+}
+
+// ValidAccountNumber reports whether number is a generated account number
+// with a correct check digit.
+func ValidAccountNumber(number string) bool {
+	if len(number) != len(accountNumberPrefix)+10 || number[:len(accountNumberPrefix)] != accountNumberPrefix {
+		return false
+	}
+	digits := number[len(accountNumberPrefix):]
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return int(digits[len(digits)-1]-'0') == luhnCheckDigit(digits[:len(digits)-1])
+}
+
+// luhnCheckDigit returns the digit that makes digits followed by it pass the
+// Luhn check.
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
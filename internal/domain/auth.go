@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type Role string
+
+// CUSTOMERs and TRADERs act on their own accounts; OPERATORs, AUDITORs and
+// ADMINs use the /admin endpoints on every account.
+const (
+	RoleCustomer Role = "CUSTOMER"
+	RoleTrader   Role = "TRADER"
+	RoleOperator Role = "OPERATOR"
+	RoleAuditor  Role = "AUDITOR"
+	RoleAdmin    Role = "ADMIN"
+)
+
+// DefaultRole is the role of credentials issued before roles existed.
+const DefaultRole = RoleTrader
+
+type Permission string
+
+const (
+	// PermissionAccountRead reads the caller's accounts, orders, cash
+	// movements and transfers.
+	PermissionAccountRead Permission = "account:read"
+	// PermissionAccountFund opens accounts, deposits, withdraws and transfers.
+	PermissionAccountFund Permission = "account:fund"
+	// PermissionOrderWrite places, amends and cancels orders.
+	PermissionOrderWrite Permission = "order:write"
+	// PermissionAdminRead reads every account.
+	PermissionAdminRead Permission = "admin:read"
+	// PermissionAccountManage freezes, unfreezes and closes accounts.
+	PermissionAccountManage Permission = "account:manage"
+	// PermissionCashSettle completes and fails pending cash movements.
+	PermissionCashSettle Permission = "cash:settle"
+	// PermissionLedgerAudit reconciles the ledger and reads the audit log.
+	PermissionLedgerAudit Permission = "ledger:audit"
+)
+
+// rolePermissions lists the permissions of every role.
+var rolePermissions = map[Role][]Permission{
+	RoleCustomer: {PermissionAccountRead, PermissionAccountFund},
+	RoleTrader:   {PermissionAccountRead, PermissionAccountFund, PermissionOrderWrite},
+	RoleOperator: {PermissionAdminRead, PermissionAccountManage, PermissionCashSettle},
+	RoleAuditor:  {PermissionAdminRead, PermissionLedgerAudit},
+	RoleAdmin: {
+		PermissionAccountRead, PermissionAccountFund, PermissionOrderWrite,
+		PermissionAdminRead, PermissionAccountManage, PermissionCashSettle, PermissionLedgerAudit,
+	},
+}
+
+// Principal is the authenticated caller of a request: an API key or the
+// subject of a JWT, its roles and the accounts it may act on.
+type Principal struct {
+	Subject    string
+	APIKeyID   *int
+	Roles      []Role
+	AccountIDs []int
+}
+
+// Can reports whether one of the principal's roles grants the permission.
+func (p *Principal) Can(permission Permission) bool {
+	for _, role := range p.Roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanAccess reports whether the principal may act on the account.
+func (p *Principal) CanAccess(accountID int) bool {
+	for _, id := range p.AccountIDs {
+		if id == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal WithPrincipal put in ctx, or nil.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// APIKey is a stored API key. Only the SHA-256 hash of the key is stored; the
+// key itself is shown once, when it is issued.
+type APIKey struct {
+	ID         int    `db:"id"`
+	Name       string `db:"name"`
+	KeyHash    string `db:"key_hash"`
+	Roles      []Role `db:"-"`
+	AccountIDs []int  `db:"-"`
+}
+
+// AuditEntry records one request to the /admin endpoints and the principal
+// that made it.
+type AuditEntry struct {
+	ID         int       `json:"id" db:"id"`
+	Actor      string    `json:"actor" db:"actor"`
+	APIKeyID   *int      `json:"api_key_id,omitempty" db:"api_key_id"`
+	Roles      []Role    `json:"roles" db:"-"`
+	Method     string    `json:"method" db:"method"`
+	Path       string    `json:"path" db:"path"`
+	StatusCode int       `json:"status_code" db:"status_code"`
+	RequestID  string    `json:"request_id" db:"request_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter selects one page of the audit log, newest first. An empty Actor
+// selects every actor.
+type AuditFilter struct {
+	Actor  string
+	Before *IDCursor
+	Limit  int
+}
+
+// AuditPage is one page of the audit log. NextCursor is empty on the last
+// page.
+type AuditPage struct {
+	Entries    []*AuditEntry `json:"entries"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
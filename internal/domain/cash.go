@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type CashMovementType string
+
+const (
+	CashMovementDeposit    CashMovementType = "DEPOSIT"
+	CashMovementWithdrawal CashMovementType = "WITHDRAWAL"
+)
+
+// CashMovementStatus follows the bank rail: a movement is PENDING until the
+// bank confirms it (COMPLETED) or rejects it (FAILED). Only a COMPLETED
+// movement changes the account balance; a PENDING withdrawal holds the cash
+// it will take.
+type CashMovementStatus string
+
+const (
+	CashMovementPending   CashMovementStatus = "PENDING"
+	CashMovementCompleted CashMovementStatus = "COMPLETED"
+	CashMovementFailed    CashMovementStatus = "FAILED"
+)
+
+// cashMovementTransitions lists, for every status, the statuses a cash
+// movement may move to from it. COMPLETED and FAILED are final.
+var cashMovementTransitions = map[CashMovementStatus][]CashMovementStatus{
+	CashMovementPending: {CashMovementCompleted, CashMovementFailed},
+}
+
+// CashMovementTransitionError reports an illegal cash movement status
+// transition.
+type CashMovementTransitionError struct {
+	From CashMovementStatus
+	To   CashMovementStatus
+}
+
+func (e *CashMovementTransitionError) Error() string {
+	return fmt.Sprintf("cash movement cannot move from %s to %s", e.From, e.To)
+}
+
+// TransitionTo returns a *CashMovementTransitionError unless the transition
+// table allows a cash movement to move from s to next.
+func (s CashMovementStatus) TransitionTo(next CashMovementStatus) error {
+	for _, allowed := range cashMovementTransitions[s] {
+		if allowed == next {
+			return nil
+		}
+	}
+	return &CashMovementTransitionError{From: s, To: next}
+}
+
+// CashMovement is a deposit into or a withdrawal from an account through an
+// external bank rail. ExternalReference is the rail's reference for it and is
+// unique per account and type.
+type CashMovement struct {
+	ID                int                `json:"id" db:"id"`
+	AccountID         int                `json:"account_id" db:"account_id"`
+	Type              CashMovementType   `json:"type" db:"type"`
+	Amount            Money              `json:"amount" db:"amount"`
+	ExternalReference string             `json:"external_reference" db:"external_reference"`
+	Status            CashMovementStatus `json:"status" db:"status"`
+	FailureReason     string             `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt         time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// CashMovementRequest requests a deposit or a withdrawal. The movement waits
+// for the bank rail to complete or fail it.
+type CashMovementRequest struct {
+	Amount            Money  `json:"amount"`
+	ExternalReference string `json:"external_reference"`
+}
+
+// FailCashMovementRequest records why the bank rail rejected a movement.
+type FailCashMovementRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CashMovementResult is the movement to return for a deposit or withdrawal
+// request. Replayed is set when an earlier request with the same external
+// reference created it.
+type CashMovementResult struct {
+	Movement *CashMovement
+	Replayed bool
+}
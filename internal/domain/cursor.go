@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// OrderCursor is the position of the last order of a page in the
+// (created_at DESC, id DESC) order of an order listing. The next page starts
+// strictly after it, so orders created while a client pages through are never
+// returned twice.
+type OrderCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c OrderCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseOrderCursor decodes a token returned by Encode.
+func ParseOrderCursor(token string) (*OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var micros int64
+	var id int
+	if n, err := fmt.Sscanf(string(raw), "%d:%d", &micros, &id); err != nil || n != 2 || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &OrderCursor{CreatedAt: time.UnixMicro(micros).UTC(), ID: id}, nil
+}
+
+// IDCursor is the id of the last row of a page of a listing ordered by id,
+// such as the account listing and the audit log.
+type IDCursor struct {
+	ID int
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c IDCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d", c.ID)))
+}
+
+// ParseIDCursor decodes a token returned by IDCursor.Encode.
+func ParseIDCursor(token string) (*IDCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var id int
+	if n, err := fmt.Sscanf(string(raw), "%d", &id); err != nil || n != 1 || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &IDCursor{ID: id}, nil
+}
@@ -3,9 +3,25 @@ package domain
 import "errors"
 
 var (
-	ErrAccountNotFound           = errors.New("account not found")
-	ErrOrderNotFound             = errors.New("order not found")
-	ErrInsufficientFunds         = errors.New("insufficient funds")
+	ErrAccountNotFound             = errors.New("account not found")
+	ErrOrderNotFound               = errors.New("order not found")
+	ErrInsufficientFunds           = errors.New("insufficient funds")
 	ErrInsufficientHoldingQuantity = errors.New("insufficient holding quantity")
-	ErrOrderNotCancelable        = errors.New("order is not in a cancelable state")
-)
\ No newline at end of file
+	ErrOrderNotCancelable          = errors.New("order is not in a cancelable state")
+	ErrOrderNotAmendable           = errors.New("order is not in an amendable state")
+	ErrInvalidAmount               = errors.New("invalid amount")
+	ErrUnbalancedEntry             = errors.New("journal entry postings do not sum to zero")
+	ErrInvalidIdempotencyKey       = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused        = errors.New("idempotency key was already used with a different request")
+	ErrInvalidCursor               = errors.New("invalid cursor")
+	ErrOrderStateConflict          = errors.New("order status was changed concurrently")
+	ErrAccountNotActive            = errors.New("account is not active")
+	ErrAccountNotEmpty             = errors.New("account still has cash, shares or open orders")
+	ErrAccountStateConflict        = errors.New("account status was changed concurrently")
+	ErrCashMovementNotFound        = errors.New("cash movement not found")
+	ErrExternalReferenceReused     = errors.New("external reference was already used with a different amount")
+	ErrCashMovementStateConflict   = errors.New("cash movement status was changed concurrently")
+	ErrTransferNotFound            = errors.New("transfer not found")
+	ErrUnauthenticated             = errors.New("missing or invalid credentials")
+	ErrForbidden                   = errors.New("access to the account is not allowed")
+)
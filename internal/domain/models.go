@@ -1,15 +1,19 @@
 package domain
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
 type Account struct {
-	ID            int       `json:"id" db:"id"`
-	AccountNumber string    `json:"account_number" db:"account_number"`
-	Balance       float64   `json:"balance" db:"balance"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	ID            int           `json:"id" db:"id"`
+	AccountNumber string        `json:"account_number" db:"account_number"`
+	Name          string        `json:"name" db:"name"`
+	Balance       Money         `json:"balance" db:"balance"`
+	Status        AccountStatus `json:"status" db:"status"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
 }
 
 type Holding struct {
@@ -22,38 +26,280 @@ type Holding struct {
 }
 
 type Order struct {
-	ID             int       `json:"id" db:"id"`
-	AccountID      int       `json:"account_id" db:"account_id"`
-	StockCode      string    `json:"stock_code" db:"stock_code"`
-	Type           string    `json:"type" db:"type"`
-	Direction      string    `json:"direction" db:"direction"`
-	Quantity       int       `json:"quantity" db:"quantity"`
-	Price          float64   `json:"price" db:"price"`
-	FilledQuantity int       `json:"filled_quantity" db:"filled_quantity"`
-	Status         string    `json:"status" db:"status"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	ID             int         `json:"id" db:"id"`
+	AccountID      int         `json:"account_id" db:"account_id"`
+	StockCode      string      `json:"stock_code" db:"stock_code"`
+	Type           OrderType   `json:"type" db:"type"`
+	Direction      Direction   `json:"direction" db:"direction"`
+	Quantity       int         `json:"quantity" db:"quantity"`
+	Price          Money       `json:"price" db:"price"`
+	StopPrice      *Money      `json:"stop_price,omitempty" db:"stop_price"`
+	FilledQuantity int         `json:"filled_quantity" db:"filled_quantity"`
+	Status         OrderStatus `json:"status" db:"status"`
+	TimeInForce    TimeInForce `json:"time_in_force" db:"time_in_force"`
+	ExpiresAt      *time.Time  `json:"expires_at,omitempty" db:"expires_at"`
+	PriorityAt     time.Time   `json:"priority_at" db:"priority_at"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// Order event types.
+const (
+	OrderEventCreated         = "CREATED"
+	OrderEventPartiallyFilled = "PARTIALLY_FILLED"
+	OrderEventFilled          = "FILLED"
+	OrderEventCanceled        = "CANCELED"
+	OrderEventAmended         = "AMENDED"
+	OrderEventExpired         = "EXPIRED"
+	OrderEventTriggered       = "TRIGGERED"
+	OrderEventRejected        = "REJECTED"
+)
+
+// Order event actors. Changes requested through the API are attributed to
+// the order's account with AccountActor.
+const (
+	ActorMatchingEngine = "matching-engine"
+	ActorSystem         = "system"
+)
+
+func AccountActor(accountID int) string {
+	return fmt.Sprintf("account:%d", accountID)
+}
+
+// OrderEvent records one transition of an order: its status and quantities
+// before and after, who made the change and why. OldStatus is nil for the
+// CREATED event; TradeID is set for fills.
+type OrderEvent struct {
+	ID                int          `json:"id" db:"id"`
+	OrderID           int          `json:"order_id" db:"order_id"`
+	Type              string       `json:"type" db:"type"`
+	OldStatus         *OrderStatus `json:"old_status" db:"old_status"`
+	NewStatus         OrderStatus  `json:"new_status" db:"new_status"`
+	OldQuantity       int          `json:"old_quantity" db:"old_quantity"`
+	NewQuantity       int          `json:"new_quantity" db:"new_quantity"`
+	OldFilledQuantity int          `json:"old_filled_quantity" db:"old_filled_quantity"`
+	NewFilledQuantity int          `json:"new_filled_quantity" db:"new_filled_quantity"`
+	TradeID           *int         `json:"trade_id,omitempty" db:"trade_id"`
+	Actor             string       `json:"actor" db:"actor"`
+	Reason            string       `json:"reason" db:"reason"`
+	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
+}
+
+type Trade struct {
+	ID            int       `json:"id" db:"id"`
+	StockCode     string    `json:"stock_code" db:"stock_code"`
+	BuyOrderID    int       `json:"buy_order_id" db:"buy_order_id"`
+	SellOrderID   int       `json:"sell_order_id" db:"sell_order_id"`
+	BuyAccountID  int       `json:"buy_account_id" db:"buy_account_id"`
+	SellAccountID int       `json:"sell_account_id" db:"sell_account_id"`
+	Price         Money     `json:"price" db:"price"`
+	Quantity      int       `json:"quantity" db:"quantity"`
+	AggressorSide Direction `json:"aggressor_side" db:"aggressor_side"`
+	ExecutedAt    time.Time `json:"executed_at" db:"executed_at"`
+}
+
+// Journal ledgers. CASH postings belong to a customer account; the other
+// ledgers are house ledgers with no account. Cash reserved for open orders is
+// tracked by holds, not by the journal, because it is still owned by the
+// account.
+const (
+	LedgerCash       = "CASH"
+	LedgerFeeRevenue = "FEE_REVENUE"
+	LedgerExternal   = "EXTERNAL"
+)
+
+// Journal entry types.
+const (
+	EntryOpeningBalance  = "OPENING_BALANCE"
+	EntryTradeSettlement = "TRADE_SETTLEMENT"
+	EntryFee             = "FEE"
+	EntryDeposit         = "DEPOSIT"
+	EntryWithdrawal      = "WITHDRAWAL"
+	EntryTransfer        = "TRANSFER"
+)
+
+// Hold kinds and statuses.
+const (
+	HoldKindCash     = "CASH"
+	HoldKindSecurity = "SECURITY"
+
+	HoldStatusActive   = "ACTIVE"
+	HoldStatusReleased = "RELEASED"
+)
+
+// Hold reserves cash (BUY) or shares (SELL) of an account for one open order,
+// or cash for one pending withdrawal. Fills consume the hold and cancels
+// release what remains of it, so the reserved amount is never recomputed from
+// the order.
+type Hold struct {
+	ID                int       `json:"id" db:"id"`
+	OrderID           *int      `json:"order_id,omitempty" db:"order_id"`
+	CashMovementID    *int      `json:"cash_movement_id,omitempty" db:"cash_movement_id"`
+	AccountID         int       `json:"account_id" db:"account_id"`
+	Kind              string    `json:"kind" db:"kind"`
+	StockCode         string    `json:"stock_code" db:"stock_code"`
+	Amount            Money     `json:"amount" db:"amount"`
+	Quantity          int       `json:"quantity" db:"quantity"`
+	RemainingAmount   Money     `json:"remaining_amount" db:"remaining_amount"`
+	RemainingQuantity int       `json:"remaining_quantity" db:"remaining_quantity"`
+	Status            string    `json:"status" db:"status"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// JournalEntry is an append-only, balanced set of postings: the amounts of
+// its postings always sum to zero.
+type JournalEntry struct {
+	ID      int    `json:"id" db:"id"`
+	Type    string `json:"type" db:"type"`
+	OrderID *int   `json:"order_id,omitempty" db:"order_id"`
+	TradeID *int   `json:"trade_id,omitempty" db:"trade_id"`
+	// CashMovementID is set on DEPOSIT and WITHDRAWAL entries.
+	CashMovementID *int `json:"cash_movement_id,omitempty" db:"cash_movement_id"`
+	// TransferID is set on TRANSFER entries.
+	TransferID  *int       `json:"transfer_id,omitempty" db:"transfer_id"`
+	Description string     `json:"description" db:"description"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	Postings    []*Posting `json:"postings" db:"-"`
+}
+
+// Posting moves Amount into (positive) or out of (negative) a ledger.
+type Posting struct {
+	ID        int       `json:"id" db:"id"`
+	EntryID   int       `json:"entry_id" db:"entry_id"`
+	Ledger    string    `json:"ledger" db:"ledger"`
+	AccountID *int      `json:"account_id,omitempty" db:"account_id"`
+	Amount    Money     `json:"amount" db:"amount"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type BalanceMismatch struct {
+	AccountID     int   `json:"account_id" db:"account_id"`
+	Balance       Money `json:"balance" db:"balance"`
+	PostedBalance Money `json:"posted_balance" db:"posted_balance"`
 }
 
+// IdempotencyRecord is the stored outcome of an order request sent with an
+// Idempotency-Key, keyed by account and key.
+type IdempotencyRecord struct {
+	AccountID    int             `db:"account_id"`
+	Key          string          `db:"idempotency_key"`
+	RequestHash  string          `db:"request_hash"`
+	StatusCode   int             `db:"status_code"`
+	ResponseBody json.RawMessage `db:"response_body"`
+	CreatedAt    time.Time       `db:"created_at"`
+}
+
+// CreateOrderRequest places a LIMIT order at Price or a MARKET order. A MARKET
+// order has no price; ProtectionPrice optionally caps what a market buy pays
+// or floors what a market sell receives. STOP and STOP_LIMIT orders are the
+// MARKET and LIMIT orders that enter matching once the last trade price
+// reaches StopPrice. TimeInForce defaults to GTC, or IOC for MARKET orders;
+// ExpiresAt is required for, and only allowed with, GTD.
 type CreateOrderRequest struct {
-	AccountID int     `json:"account_id"`
-	StockCode string  `json:"stock_code"`
-	Type      string  `json:"type"`
-	Direction string  `json:"direction"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	AccountID       int         `json:"account_id"`
+	StockCode       string      `json:"stock_code"`
+	Type            OrderType   `json:"type"`
+	Direction       Direction   `json:"direction"`
+	Quantity        int         `json:"quantity"`
+	Price           Money       `json:"price"`
+	ProtectionPrice *Money      `json:"protection_price,omitempty"`
+	StopPrice       *Money      `json:"stop_price,omitempty"`
+	TimeInForce     TimeInForce `json:"time_in_force,omitempty"`
+	ExpiresAt       *time.Time  `json:"expires_at,omitempty"`
+}
+
+// EffectiveTimeInForce returns the requested time in force or the default for
+// the order type.
+func (r *CreateOrderRequest) EffectiveTimeInForce() TimeInForce {
+	if r.TimeInForce != "" {
+		return r.TimeInForce
+	}
+	if r.Type == OrderTypeMarket {
+		return TimeInForceIOC
+	}
+	return TimeInForceGTC
+}
+
+// CreateOrderResult is the order to return for a create request and the
+// status code to return it with. Replayed is set when the result is the stored
+// response of an earlier request with the same idempotency key.
+type CreateOrderResult struct {
+	Order      *Order
+	StatusCode int
+	Replayed   bool
+}
+
+// CreateAccountRequest opens a new account with a zero balance. The account
+// number is generated.
+type CreateAccountRequest struct {
+	Name string `json:"name"`
+}
+
+// AccountFilter selects one page of accounts, in id order. A nil AccountIDs
+// selects every account.
+type AccountFilter struct {
+	AccountIDs []int
+	Status     AccountStatus
+	After      *IDCursor
+	Limit      int
+}
+
+// AccountPage is one page of an account listing. NextCursor is empty on the
+// last page.
+type AccountPage struct {
+	Accounts   []*Account `json:"accounts"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// AmendOrderRequest changes the limit price or the quantity of an open order.
+// Fields left out are not changed.
+type AmendOrderRequest struct {
+	Price    *Money `json:"price,omitempty"`
+	Quantity *int   `json:"quantity,omitempty"`
+}
+
+// OrderFilter selects the orders of one account. Empty fields do not filter;
+// CreatedFrom is inclusive and CreatedTo exclusive.
+type OrderFilter struct {
+	AccountID   int
+	Status      OrderStatus
+	Direction   Direction
+	StockCode   string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	After       *OrderCursor
+	Limit       int
+}
+
+// OrderPage is one page of an order listing, newest first. NextCursor is
+// empty on the last page.
+type OrderPage struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
 }
 
 type BalanceResponse struct {
-	AccountNumber string  `json:"account_number"`
-	Balance       float64 `json:"balance"`
+	AccountNumber string `json:"account_number"`
+	Balance       Money  `json:"balance"`
+	Available     Money  `json:"available"`
+	Reserved      Money  `json:"reserved"`
 }
 
 type HoldingResponse struct {
 	StockCode string `json:"stock_code"`
 	Quantity  int    `json:"quantity"`
+	Available int    `json:"available"`
+	Reserved  int    `json:"reserved"`
+}
+
+type ReconciliationResponse struct {
+	Balanced          bool               `json:"balanced"`
+	UnbalancedEntries []int              `json:"unbalanced_entries"`
+	Mismatches        []*BalanceMismatch `json:"mismatches"`
 }
 
 type ErrorResponse struct {
-	Error string `json:"error"`
-}
\ No newline at end of file
+	Error      string           `json:"error"`
+	Violations []FieldViolation `json:"violations,omitempty"`
+}
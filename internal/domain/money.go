@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MoneyScale is the number of fractional digits kept by Money. It matches the
+// DECIMAL(15,2) columns used for balances and prices.
+const MoneyScale = 2
+
+const moneyUnit = 100 // 10^MoneyScale
+
+// Money is an exact decimal amount with a fixed scale of MoneyScale, stored as
+// an integer number of minor units (hundredths). Sums and differences of Money
+// values and products with integer quantities are exact. The only operations
+// that can produce digits beyond the scale are ratios, which round half to
+// even (see MulRatio). Parsing never rounds: amounts with more than MoneyScale
+// fractional digits are rejected with ErrInvalidAmount.
+type Money int64
+
+// NewMoney returns the amount of whole currency units, e.g. NewMoney(50000).
+func NewMoney(units int64) Money {
+	return Money(units * moneyUnit)
+}
+
+// ParseMoney parses a plain decimal string such as "50000" or "-12.5".
+// Exponent notation and fractions such as "1/2" are rejected.
+func ParseMoney(s string) (Money, error) {
+	if strings.ContainsAny(s, "eE/") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	r.Mul(r, big.NewRat(moneyUnit, 1))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, s, MoneyScale)
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, s)
+	}
+	return Money(r.Num().Int64()), nil
+}
+
+// MulInt returns the amount multiplied by an integer quantity.
+func (m Money) MulInt(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// MulRatio returns m * numerator / denominator rounded half to even to the
+// money scale, e.g. m.MulRatio(15, 10000) for a 15 basis point fee.
+func (m Money) MulRatio(numerator, denominator int64) Money {
+	r := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(numerator)),
+		big.NewInt(denominator),
+	)
+
+	quotient, remainder := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	twice := new(big.Int).Abs(remainder)
+	twice.Lsh(twice, 1)
+	if cmp := twice.Cmp(r.Denom()); cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1) {
+		if remainder.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return Money(quotient.Int64())
+}
+
+func (m Money) IsZero() bool {
+	return m == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m < 0
+}
+
+// String formats the amount with exactly MoneyScale fractional digits.
+func (m Money) String() string {
+	// The magnitude is negated as an unsigned number: -m overflows for the
+	// smallest Money, whose magnitude only fits in a uint64.
+	sign, v := "", uint64(m)
+	if m < 0 {
+		sign, v = "-", -uint64(m)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/moneyUnit, v%moneyUnit)
+}
+
+// MarshalJSON encodes the amount as a JSON number without trailing fractional
+// zeros, e.g. 50000 or 12.5, so no binary floating point is involved.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(trimFraction(m.String())), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a JSON string holding a decimal.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		unquoted, err := strconv.Unquote(string(data))
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+		}
+		data = []byte(unquoted)
+	}
+
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for DECIMAL columns. NULL scans as zero.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		if v > math.MaxInt64/moneyUnit || v < math.MinInt64/moneyUnit {
+			return fmt.Errorf("%w: %d is out of range", ErrInvalidAmount, v)
+		}
+		*m = NewMoney(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+}
+
+// Value implements driver.Valuer, binding the amount as an exact decimal string.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func trimFraction(s string) string {
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
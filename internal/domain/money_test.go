@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{input: "0", want: 0},
+		{input: "50000", want: 5000000},
+		{input: "12.5", want: 1250},
+		{input: "12.50", want: 1250},
+		{input: "0.01", want: 1},
+		{input: "-12.5", want: -1250},
+		{input: "-0.01", want: -1},
+		{input: "92233720368547758.07", want: math.MaxInt64},
+		{input: "-92233720368547758.08", want: math.MinInt64},
+		{input: "1.234", wantErr: true},
+		{input: "-0.001", wantErr: true},
+		{input: "92233720368547758.08", wantErr: true},
+		{input: "-92233720368547758.09", wantErr: true},
+		{input: "1e3", wantErr: true},
+		{input: "1E3", wantErr: true},
+		{input: "", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "1/2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("ParseMoney(%q) error = %v, want ErrInvalidAmount", tt.input, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: 0, want: "0.00"},
+		{money: 1, want: "0.01"},
+		{money: 1250, want: "12.50"},
+		{money: 5000000, want: "50000.00"},
+		{money: -1, want: "-0.01"},
+		{money: -105, want: "-1.05"},
+		{money: math.MaxInt64, want: "92233720368547758.07"},
+		{money: math.MinInt64, want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.money), got, tt.want)
+		}
+		parsed, err := ParseMoney(tt.want)
+		if err != nil || parsed != tt.money {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d", tt.want, parsed, err, tt.money)
+		}
+	}
+}
+
+func TestMoneyMulRatio(t *testing.T) {
+	tests := []struct {
+		money                  Money
+		numerator, denominator int64
+		want                   Money
+	}{
+		{money: 10000, numerator: 15, denominator: 10000, want: 15},
+		// Halves round to the even neighbour.
+		{money: 5, numerator: 1, denominator: 2, want: 2},
+		{money: 7, numerator: 1, denominator: 2, want: 4},
+		{money: -5, numerator: 1, denominator: 2, want: -2},
+		{money: -7, numerator: 1, denominator: 2, want: -4},
+		// Anything else rounds to the nearest.
+		{money: 10, numerator: 1, denominator: 3, want: 3},
+		{money: 20, numerator: 1, denominator: 3, want: 7},
+		{money: -20, numerator: 1, denominator: 3, want: -7},
+		{money: 5000000, numerator: 10500, denominator: 10000, want: 5250000},
+		{money: 0, numerator: 15, denominator: 10000, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.MulRatio(tt.numerator, tt.denominator); got != tt.want {
+			t.Errorf("Money(%d).MulRatio(%d, %d) = %d, want %d", int64(tt.money), tt.numerator, tt.denominator, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		money Money
+		json  string
+	}{
+		{money: 0, json: "0"},
+		{money: 5000000, json: "50000"},
+		{money: 1250, json: "12.5"},
+		{money: 1, json: "0.01"},
+		{money: -1250, json: "-12.5"},
+		{money: math.MinInt64, json: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		encoded, err := json.Marshal(tt.money)
+		if err != nil || string(encoded) != tt.json {
+			t.Errorf("json.Marshal(Money(%d)) = %s, %v, want %s", int64(tt.money), encoded, err, tt.json)
+		}
+
+		var decoded Money
+		if err := json.Unmarshal(encoded, &decoded); err != nil || decoded != tt.money {
+			t.Errorf("json.Unmarshal(%s) = %d, %v, want %d", encoded, decoded, err, tt.money)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{input: `12.5`, want: 1250},
+		{input: `"12.5"`, want: 1250},
+		{input: `null`, want: 0},
+		{input: `1.234`, wantErr: true},
+		{input: `"1.234"`, wantErr: true},
+		{input: `1e3`, wantErr: true},
+		{input: `"abc"`, wantErr: true},
+		{input: `100000000000000000`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.input), &got)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("json.Unmarshal(%s) error = %v, want ErrInvalidAmount", tt.input, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("json.Unmarshal(%s) = %d, %v, want %d", tt.input, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package domain
+
+import "fmt"
+
+type OrderStatus string
+
+const (
+	OrderStatusPending  OrderStatus = "PENDING"
+	OrderStatusPartial  OrderStatus = "PARTIAL"
+	OrderStatusFilled   OrderStatus = "FILLED"
+	OrderStatusCanceled OrderStatus = "CANCELED"
+	OrderStatusExpired  OrderStatus = "EXPIRED"
+
+	// OrderStatusTriggerPending is the status of a stop order waiting for
+	// its stop price. It holds its reservation but is not in the book.
+	OrderStatusTriggerPending OrderStatus = "TRIGGER_PENDING"
+)
+
+type OrderType string
+
+// STOP and STOP_LIMIT orders wait in TRIGGER_PENDING until the last trade
+// price of their stock code reaches the stop price, then enter matching as a
+// MARKET or LIMIT order respectively.
+const (
+	OrderTypeLimit     OrderType = "LIMIT"
+	OrderTypeMarket    OrderType = "MARKET"
+	OrderTypeStop      OrderType = "STOP"
+	OrderTypeStopLimit OrderType = "STOP_LIMIT"
+)
+
+// TimeInForce says how long an order may stay in the book. GTC orders rest
+// until filled or canceled, DAY orders until the day's cutoff and GTD orders
+// until their ExpiresAt. IOC and FOK orders never rest: IOC fills what it can
+// immediately, FOK fills completely or not at all, and the rest is canceled.
+type TimeInForce string
+
+const (
+	TimeInForceDay TimeInForce = "DAY"
+	TimeInForceGTC TimeInForce = "GTC"
+	TimeInForceIOC TimeInForce = "IOC"
+	TimeInForceFOK TimeInForce = "FOK"
+	TimeInForceGTD TimeInForce = "GTD"
+)
+
+type Direction string
+
+const (
+	DirectionBuy  Direction = "BUY"
+	DirectionSell Direction = "SELL"
+)
+
+// orderTransitions lists, for every status, the statuses an order may move
+// to from it. PARTIAL may move to itself because every further partial fill
+// is a transition of its own. Statuses without an entry are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusTriggerPending: {OrderStatusPending, OrderStatusCanceled, OrderStatusExpired},
+	OrderStatusPending:        {OrderStatusPartial, OrderStatusFilled, OrderStatusCanceled, OrderStatusExpired},
+	OrderStatusPartial:        {OrderStatusPartial, OrderStatusFilled, OrderStatusCanceled, OrderStatusExpired},
+}
+
+// OrderTransitionError reports an illegal order status transition.
+type OrderTransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+}
+
+func (e *OrderTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusTriggerPending, OrderStatusPending, OrderStatusPartial, OrderStatusFilled, OrderStatusCanceled, OrderStatusExpired:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether an order in this status can still trade, now or once
+// triggered.
+func (s OrderStatus) IsOpen() bool {
+	return len(orderTransitions[s]) > 0
+}
+
+// TransitionTo returns an *OrderTransitionError unless the transition table
+// allows an order to move from s to next.
+func (s OrderStatus) TransitionTo(next OrderStatus) error {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return nil
+		}
+	}
+	return &OrderTransitionError{From: s, To: next}
+}
+
+// FillStatus is the status of an order of the given quantity once
+// filledQuantity of it has been filled.
+func FillStatus(quantity, filledQuantity int) OrderStatus {
+	switch {
+	case filledQuantity == 0:
+		return OrderStatusPending
+	case filledQuantity < quantity:
+		return OrderStatusPartial
+	default:
+		return OrderStatusFilled
+	}
+}
+
+func (t OrderType) IsValid() bool {
+	switch t {
+	case OrderTypeLimit, OrderTypeMarket, OrderTypeStop, OrderTypeStopLimit:
+		return true
+	}
+	return false
+}
+
+// IsStop reports whether orders of this type wait for a stop price.
+func (t OrderType) IsStop() bool {
+	return t == OrderTypeStop || t == OrderTypeStopLimit
+}
+
+// IsMarket reports whether orders of this type trade at any price up to their
+// protection price instead of at a limit price.
+func (t OrderType) IsMarket() bool {
+	return t == OrderTypeMarket || t == OrderTypeStop
+}
+
+func (t TimeInForce) IsValid() bool {
+	switch t {
+	case TimeInForceDay, TimeInForceGTC, TimeInForceIOC, TimeInForceFOK, TimeInForceGTD:
+		return true
+	}
+	return false
+}
+
+// IsImmediate reports whether an order with this time in force is canceled
+// instead of resting when it cannot be filled on arrival.
+func (t TimeInForce) IsImmediate() bool {
+	return t == TimeInForceIOC || t == TimeInForceFOK
+}
+
+func (d Direction) IsValid() bool {
+	return d == DirectionBuy || d == DirectionSell
+}
+
+// Opposite returns the side of the book an order with this direction trades
+// against.
+func (d Direction) Opposite() Direction {
+	if d == DirectionBuy {
+		return DirectionSell
+	}
+	return DirectionBuy
+}
+
+// StopTriggered reports whether a stop order in the given direction is
+// triggered by a trade at lastPrice: a buy stop when the price rises to the
+// stop price, a sell stop when it falls to it.
+func StopTriggered(direction Direction, stopPrice, lastPrice Money) bool {
+	if direction == DirectionBuy {
+		return lastPrice >= stopPrice
+	}
+	return lastPrice <= stopPrice
+}
+
+// RestsInBook reports whether whatever the order cannot fill on arrival stays
+// in the book. Market orders and IOC and FOK orders are canceled instead.
+func (o *Order) RestsInBook() bool {
+	return !o.Type.IsMarket() && !o.TimeInForce.IsImmediate()
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+type TransferKind string
+
+const (
+	TransferCash     TransferKind = "CASH"
+	TransferSecurity TransferKind = "SECURITY"
+)
+
+// Transfer moves cash (Amount) or shares of StockCode (Quantity) from one
+// account to another. Transfers are final and never updated.
+type Transfer struct {
+	ID            int          `json:"id" db:"id"`
+	FromAccountID int          `json:"from_account_id" db:"from_account_id"`
+	ToAccountID   int          `json:"to_account_id" db:"to_account_id"`
+	Kind          TransferKind `json:"kind" db:"kind"`
+	StockCode     string       `json:"stock_code,omitempty" db:"stock_code"`
+	Amount        Money        `json:"amount" db:"amount"`
+	Quantity      int          `json:"quantity" db:"quantity"`
+	Memo          string       `json:"memo" db:"memo"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+}
+
+// CreateTransferRequest requests a transfer of cash when StockCode is empty,
+// and of shares of StockCode otherwise.
+type CreateTransferRequest struct {
+	FromAccountID int    `json:"from_account_id"`
+	ToAccountID   int    `json:"to_account_id"`
+	StockCode     string `json:"stock_code,omitempty"`
+	Amount        Money  `json:"amount,omitempty"`
+	Quantity      int    `json:"quantity,omitempty"`
+	Memo          string `json:"memo,omitempty"`
+}
+
+// Kind is the kind of transfer the request asks for.
+func (r *CreateTransferRequest) Kind() TransferKind {
+	if r.StockCode == "" {
+		return TransferCash
+	}
+	return TransferSecurity
+}
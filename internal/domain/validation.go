@@ -0,0 +1,283 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxStockCodeLength = 32
+
+const maxAccountNameLength = 100
+
+const maxExternalReferenceLength = 255
+
+const maxTransferMemoLength = 255
+
+// MaxAmount is the largest amount a DECIMAL(15,2) column can hold.
+const MaxAmount Money = 999_999_999_999_999
+
+// MaxOrderQuantity is the largest quantity of an order.
+const MaxOrderQuantity = 1_000_000_000
+
+// FieldViolation describes why one field of a request is invalid. Field is the
+// JSON name of the field.
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of a request, so a client can fix
+// them all at once instead of one per round trip.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Field + ": " + violation.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Violations = append(e.Violations, FieldViolation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns e as an error, or nil when nothing was violated.
+func (e *ValidationError) err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks the request before any account state is read. It returns a
+// *ValidationError listing every violation.
+func (r *CreateOrderRequest) Validate() error {
+	v := &ValidationError{}
+
+	if r.AccountID <= 0 {
+		v.add("account_id", "must be a positive integer")
+	}
+
+	switch {
+	case strings.TrimSpace(r.StockCode) == "":
+		v.add("stock_code", "must not be empty")
+	case len(r.StockCode) > maxStockCodeLength:
+		v.add("stock_code", "must be at most %d characters", maxStockCodeLength)
+	}
+
+	if !r.Type.IsValid() {
+		v.add("type", "must be one of %s, %s, %s or %s", OrderTypeLimit, OrderTypeMarket, OrderTypeStop, OrderTypeStopLimit)
+	}
+	if !r.Direction.IsValid() {
+		v.add("direction", "must be %s or %s", DirectionBuy, DirectionSell)
+	}
+
+	switch {
+	case r.Quantity <= 0:
+		v.add("quantity", "must be greater than 0")
+	case r.Quantity > MaxOrderQuantity:
+		v.add("quantity", "must be at most %d", MaxOrderQuantity)
+	}
+
+	switch {
+	case r.Type.IsMarket():
+		if r.Price != 0 {
+			v.add("price", "must not be set for %s orders; use protection_price", r.Type)
+		}
+		if r.ProtectionPrice != nil {
+			r.validatePrice(v, "protection_price", *r.ProtectionPrice)
+		}
+	case r.Type.IsValid():
+		r.validatePrice(v, "price", r.Price)
+		if r.ProtectionPrice != nil {
+			v.add("protection_price", "is only allowed for %s and %s orders", OrderTypeMarket, OrderTypeStop)
+		}
+	}
+
+	switch {
+	case r.Type.IsStop() && r.StopPrice == nil:
+		v.add("stop_price", "is required for %s orders", r.Type)
+	case r.Type.IsStop():
+		r.validatePrice(v, "stop_price", *r.StopPrice)
+	case r.StopPrice != nil:
+		v.add("stop_price", "is only allowed for %s and %s orders", OrderTypeStop, OrderTypeStopLimit)
+	}
+
+	timeInForce := r.EffectiveTimeInForce()
+	switch {
+	case !timeInForce.IsValid():
+		v.add("time_in_force", "must be one of %s, %s, %s, %s or %s",
+			TimeInForceDay, TimeInForceGTC, TimeInForceIOC, TimeInForceFOK, TimeInForceGTD)
+	case r.Type == OrderTypeMarket && !timeInForce.IsImmediate():
+		v.add("time_in_force", "must be %s or %s for %s orders", TimeInForceIOC, TimeInForceFOK, OrderTypeMarket)
+	}
+
+	switch {
+	case timeInForce == TimeInForceGTD && r.ExpiresAt == nil:
+		v.add("expires_at", "is required for %s orders", TimeInForceGTD)
+	case timeInForce != TimeInForceGTD && r.ExpiresAt != nil:
+		v.add("expires_at", "is only allowed for %s orders", TimeInForceGTD)
+	case r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()):
+		v.add("expires_at", "must be in the future")
+	}
+
+	return v.err()
+}
+
+// ValidateLimitPrice checks the limit price a MARKET or STOP order gets from
+// the book or from its stop price when it is created: quantity times that
+// price, which a buy reserves, must fit in an amount.
+func (r *CreateOrderRequest) ValidateLimitPrice(price Money) error {
+	v := &ValidationError{}
+
+	if price > MaxAmount/Money(r.Quantity) {
+		v.add("quantity", "times the limit price %s must not exceed %s", price, MaxAmount)
+	}
+
+	return v.err()
+}
+
+func (r *CreateOrderRequest) validatePrice(v *ValidationError, field string, price Money) {
+	if price <= 0 {
+		v.add(field, "must be greater than 0")
+	} else if r.Quantity > 0 && price > MaxAmount/Money(r.Quantity) {
+		v.add(field, "%s times quantity must not exceed %s", field, MaxAmount)
+	}
+}
+
+// Validate checks the amendment on its own; whether it fits the order is
+// checked when the order is read.
+func (r *AmendOrderRequest) Validate() error {
+	v := &ValidationError{}
+
+	if r.Price == nil && r.Quantity == nil {
+		v.add("price", "price or quantity must be set")
+	}
+	switch {
+	case r.Quantity != nil && *r.Quantity <= 0:
+		v.add("quantity", "must be greater than 0")
+	case r.Quantity != nil && *r.Quantity > MaxOrderQuantity:
+		v.add("quantity", "must be at most %d", MaxOrderQuantity)
+	}
+	if r.Price != nil && *r.Price <= 0 {
+		v.add("price", "must be greater than 0")
+	}
+
+	return v.err()
+}
+
+// ValidateFor checks the amendment against the order it applies to.
+func (r *AmendOrderRequest) ValidateFor(order *Order) error {
+	v := &ValidationError{}
+
+	if r.Price != nil && order.Type.IsMarket() {
+		v.add("price", "cannot be amended for %s orders", order.Type)
+	}
+	if r.Quantity != nil && *r.Quantity <= order.FilledQuantity {
+		v.add("quantity", "must be greater than the filled quantity %d", order.FilledQuantity)
+	}
+
+	quantity, price := order.Quantity, order.Price
+	if r.Quantity != nil {
+		quantity = *r.Quantity
+	}
+	if r.Price != nil {
+		price = *r.Price
+	}
+	if quantity > 0 && price > MaxAmount/Money(quantity) {
+		v.add("price", "price times quantity must not exceed %s", MaxAmount)
+	}
+
+	return v.err()
+}
+
+func (r *CreateAccountRequest) Validate() error {
+	v := &ValidationError{}
+
+	name := strings.TrimSpace(r.Name)
+	switch {
+	case name == "":
+		v.add("name", "is required")
+	case utf8.RuneCountInString(name) > maxAccountNameLength:
+		v.add("name", "must be at most %d characters", maxAccountNameLength)
+	}
+
+	return v.err()
+}
+
+func (r *CashMovementRequest) Validate() error {
+	v := &ValidationError{}
+
+	switch {
+	case r.Amount <= 0:
+		v.add("amount", "must be greater than 0")
+	case r.Amount > MaxAmount:
+		v.add("amount", "must not exceed %s", MaxAmount)
+	}
+
+	switch {
+	case strings.TrimSpace(r.ExternalReference) == "":
+		v.add("external_reference", "is required")
+	case len(r.ExternalReference) > maxExternalReferenceLength:
+		v.add("external_reference", "must be at most %d characters", maxExternalReferenceLength)
+	}
+
+	return v.err()
+}
+
+func (r *FailCashMovementRequest) Validate() error {
+	v := &ValidationError{}
+
+	if strings.TrimSpace(r.Reason) == "" {
+		v.add("reason", "is required")
+	}
+
+	return v.err()
+}
+
+func (r *CreateTransferRequest) Validate() error {
+	v := &ValidationError{}
+
+	if r.FromAccountID <= 0 {
+		v.add("from_account_id", "must be greater than 0")
+	}
+	switch {
+	case r.ToAccountID <= 0:
+		v.add("to_account_id", "must be greater than 0")
+	case r.ToAccountID == r.FromAccountID:
+		v.add("to_account_id", "must differ from from_account_id")
+	}
+
+	if r.Kind() == TransferCash {
+		switch {
+		case r.Amount <= 0:
+			v.add("amount", "must be greater than 0")
+		case r.Amount > MaxAmount:
+			v.add("amount", "must not exceed %s", MaxAmount)
+		}
+		if r.Quantity != 0 {
+			v.add("quantity", "is only allowed with stock_code")
+		}
+	} else {
+		if len(r.StockCode) > maxStockCodeLength {
+			v.add("stock_code", "must be at most %d characters", maxStockCodeLength)
+		}
+		if r.Quantity <= 0 {
+			v.add("quantity", "must be greater than 0")
+		}
+		if r.Amount != 0 {
+			v.add("amount", "is not allowed with stock_code")
+		}
+	}
+
+	if len(r.Memo) > maxTransferMemoLength {
+		v.add("memo", "must be at most %d characters", maxTransferMemoLength)
+	}
+
+	return v.err()
+}
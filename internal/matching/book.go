@@ -0,0 +1,139 @@
+package matching
+
+import (
+	"sort"
+
+	"mini-ledger/internal/domain"
+)
+
+// Execution is a single fill between an incoming (taker) order and a resting
+// (maker) order. Executions always take place at the maker's price.
+type Execution struct {
+	Taker    *domain.Order
+	Maker    *domain.Order
+	Quantity int
+	Price    domain.Money
+}
+
+// OrderBook is the limit order book of a single stock code. It is built from
+// the resting orders loaded inside the caller's transaction, so the book never
+// outlives that transaction and is always consistent with the database.
+type OrderBook struct {
+	StockCode string
+	bids      []*domain.Order
+	asks      []*domain.Order
+}
+
+func NewOrderBook(stockCode string, resting []*domain.Order) *OrderBook {
+	book := &OrderBook{StockCode: stockCode}
+	for _, order := range resting {
+		book.add(order)
+	}
+	return book
+}
+
+// Match crosses the incoming order against the opposite side of the book in
+// price-time priority. FilledQuantity and Status of the incoming order and of
+// every touched resting order are updated in place. Any unfilled remainder of
+// the incoming order is left resting in the book.
+func (b *OrderBook) Match(incoming *domain.Order) []Execution {
+	var executions []Execution
+
+	for remaining(incoming) > 0 {
+		maker := b.best(incoming.Direction.Opposite())
+		if maker == nil || !crosses(incoming, maker) {
+			break
+		}
+
+		quantity := min(remaining(incoming), remaining(maker))
+		fill(incoming, quantity)
+		fill(maker, quantity)
+
+		executions = append(executions, Execution{
+			Taker:    incoming,
+			Maker:    maker,
+			Quantity: quantity,
+			Price:    maker.Price,
+		})
+
+		if remaining(maker) == 0 {
+			b.removeBest(maker.Direction)
+		}
+	}
+
+	if remaining(incoming) > 0 {
+		b.add(incoming)
+	}
+
+	return executions
+}
+
+func (b *OrderBook) add(order *domain.Order) {
+	switch order.Direction {
+	case domain.DirectionBuy:
+		b.bids = insert(b.bids, order, func(a, o *domain.Order) bool { return a.Price > o.Price })
+	case domain.DirectionSell:
+		b.asks = insert(b.asks, order, func(a, o *domain.Order) bool { return a.Price < o.Price })
+	}
+}
+
+func (b *OrderBook) best(direction domain.Direction) *domain.Order {
+	side := b.side(direction)
+	if len(side) == 0 {
+		return nil
+	}
+	return side[0]
+}
+
+func (b *OrderBook) removeBest(direction domain.Direction) {
+	switch direction {
+	case domain.DirectionBuy:
+		b.bids = b.bids[1:]
+	case domain.DirectionSell:
+		b.asks = b.asks[1:]
+	}
+}
+
+func (b *OrderBook) side(direction domain.Direction) []*domain.Order {
+	if direction == domain.DirectionBuy {
+		return b.bids
+	}
+	return b.asks
+}
+
+// insert places the order after every order with a better or equal price,
+// which keeps equal-priced orders in time priority order.
+func insert(side []*domain.Order, order *domain.Order, better func(a, o *domain.Order) bool) []*domain.Order {
+	i := sort.Search(len(side), func(i int) bool {
+		return better(order, side[i]) || (order.Price == side[i].Price && arrivedBefore(order, side[i]))
+	})
+	side = append(side, nil)
+	copy(side[i+1:], side[i:])
+	side[i] = order
+	return side
+}
+
+// arrivedBefore orders by time priority, which is the arrival time unless the
+// order was amended since.
+func arrivedBefore(a, b *domain.Order) bool {
+	if !a.PriorityAt.Equal(b.PriorityAt) {
+		return a.PriorityAt.Before(b.PriorityAt)
+	}
+	return a.ID < b.ID
+}
+
+func crosses(incoming, resting *domain.Order) bool {
+	if incoming.Direction == domain.DirectionBuy {
+		return incoming.Price >= resting.Price
+	}
+	return incoming.Price <= resting.Price
+}
+
+func remaining(order *domain.Order) int {
+	return order.Quantity - order.FilledQuantity
+}
+
+func fill(order *domain.Order, quantity int) {
+	order.FilledQuantity += quantity
+	order.Status = domain.FillStatus(order.Quantity, order.FilledQuantity)
+}
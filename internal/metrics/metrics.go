@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mini-ledger/internal/db"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "mini_ledger"
+
+// Metrics holds the Prometheus collectors of the server. They are registered
+// on a registry of their own, served by Handler on the admin port.
+type Metrics struct {
+	registry         *prometheus.Registry
+	httpDuration     *prometheus.HistogramVec
+	ordersCreated    *prometheus.CounterVec
+	ordersCanceled   prometheus.Counter
+	ordersRejected   *prometheus.CounterVec
+	matchingDuration prometheus.Histogram
+}
+
+func New(database *db.Database) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of API requests by method, route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		ordersCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "orders_created_total",
+			Help:      "Orders created, by order type.",
+		}, []string{"type"}),
+		ordersCanceled: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "orders_canceled_total",
+			Help:      "Orders closed before filling: canceled on request, expired, or IOC, FOK and market remainders.",
+		}),
+		ordersRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "orders_rejected_total",
+			Help:      "Order creations rejected, by reason.",
+		}, []string{"reason"}),
+		matchingDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "matching_duration_seconds",
+			Help:      "Time to match an order against the book and settle its executions.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(database.DB.DB, "mini_ledger"),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tx_retries_total",
+			Help:      "Transactions retried after CockroachDB aborted them with a retryable error.",
+		}, func() float64 { return float64(database.Retries()) }),
+		m.httpDuration,
+		m.ordersCreated,
+		m.ordersCanceled,
+		m.ordersRejected,
+		m.matchingDuration,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest records the latency of a request. route is the pattern
+// the request matched, such as /api/v1/orders/{orderID}, so that the labels
+// stay bounded.
+func (m *Metrics) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	m.httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+func (m *Metrics) OrderCreated(orderType string) {
+	m.ordersCreated.WithLabelValues(orderType).Inc()
+}
+
+func (m *Metrics) OrderCanceled() {
+	m.ordersCanceled.Inc()
+}
+
+func (m *Metrics) OrderRejected(reason string) {
+	m.ordersRejected.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) ObserveMatching(duration time.Duration) {
+	m.matchingDuration.Observe(duration.Seconds())
+}
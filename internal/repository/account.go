@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"context"
+	"fmt"
 	"mini-ledger/internal/db"
 	"mini-ledger/internal/domain"
+
+	"github.com/lib/pq"
 )
 
 type accountRepository struct{}
@@ -11,18 +15,114 @@ func NewAccountRepository() AccountRepository {
 	return &accountRepository{}
 }
 
-func (r *accountRepository) GetByID(querier db.Querier, id int) (*domain.Account, error) {
+func (r *accountRepository) Create(ctx context.Context, querier db.Querier, account *domain.Account) (*domain.Account, error) {
+	ctx, span := tracer.Start(ctx, "accountRepository.Create")
+	defer span.End()
+
+	query := `INSERT INTO accounts (account_number, name, balance, status) VALUES ($1, $2, 0, $3) RETURNING id`
+
+	var id int
+	err := querier.GetContext(ctx, &id, query, account.AccountNumber, account.Name, account.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, querier, id)
+}
+
+func (r *accountRepository) GetByID(ctx context.Context, querier db.Querier, id int) (*domain.Account, error) {
+	ctx, span := tracer.Start(ctx, "accountRepository.GetByID")
+	defer span.End()
+
 	var account domain.Account
-	query := `SELECT id, account_number, balance, created_at, updated_at FROM accounts WHERE id = $1`
-	err := querier.Get(&account, query, id)
+	query := `SELECT id, account_number, name, balance, status, created_at, updated_at FROM accounts WHERE id = $1`
+	err := querier.GetContext(ctx, &account, query, id)
 	if err != nil {
 		return nil, err
 	}
 	return &account, nil
 }
 
-func (r *accountRepository) UpdateBalance(querier db.Querier, id int, balance float64) error {
-	query := `UPDATE accounts SET balance = $1, updated_at = NOW() WHERE id = $2`
-	_, err := querier.Exec(query, balance, id)
-	return err
-}
\ No newline at end of file
+// GetForUpdate locks and returns the given accounts in id order. Locking in
+// id order means two transactions locking the same accounts never wait for
+// each other in a cycle.
+func (r *accountRepository) GetForUpdate(ctx context.Context, querier db.Querier, ids ...int) ([]*domain.Account, error) {
+	ctx, span := tracer.Start(ctx, "accountRepository.GetForUpdate")
+	defer span.End()
+
+	accounts := []*domain.Account{}
+	query := `SELECT id, account_number, name, balance, status, created_at, updated_at FROM accounts
+			  WHERE id = ANY($1) ORDER BY id FOR UPDATE`
+	err := querier.SelectContext(ctx, &accounts, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// List returns up to filter.Limit accounts matching the filter in id order,
+// starting after filter.After.
+func (r *accountRepository) List(ctx context.Context, querier db.Querier, filter *domain.AccountFilter) ([]*domain.Account, error) {
+	ctx, span := tracer.Start(ctx, "accountRepository.List")
+	defer span.End()
+
+	query := `SELECT id, account_number, name, balance, status, created_at, updated_at FROM accounts WHERE TRUE`
+	args := []interface{}{}
+
+	if filter.AccountIDs != nil {
+		args = append(args, pq.Array(filter.AccountIDs))
+		query += fmt.Sprintf(" AND id = ANY($%d)", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.ID)
+		query += fmt.Sprintf(" AND id > $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+
+	accounts := []*domain.Account{}
+	err := querier.SelectContext(ctx, &accounts, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// NextNumberSequence returns the next value of the sequence account numbers
+// are generated from.
+func (r *accountRepository) NextNumberSequence(ctx context.Context, querier db.Querier) (int64, error) {
+	ctx, span := tracer.Start(ctx, "accountRepository.NextNumberSequence")
+	defer span.End()
+
+	var sequence int64
+	err := querier.GetContext(ctx, &sequence, `SELECT nextval('account_number_seq')`)
+	return sequence, err
+}
+
+func (r *accountRepository) UpdateStatus(ctx context.Context, querier db.Querier, id int, from, to domain.AccountStatus) error {
+	ctx, span := tracer.Start(ctx, "accountRepository.UpdateStatus")
+	defer span.End()
+
+	if err := from.TransitionTo(to); err != nil {
+		return err
+	}
+
+	query := `UPDATE accounts SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`
+	result, err := querier.ExecContext(ctx, query, to, id, from)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrAccountStateConflict
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+
+	"github.com/lib/pq"
+)
+
+type apiKeyRepository struct{}
+
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepository{}
+}
+
+// GetActiveByHash returns the unrevoked API key with the given hash, its roles
+// and the accounts it may access, or nil if there is none.
+func (r *apiKeyRepository) GetActiveByHash(ctx context.Context, querier db.Querier, keyHash string) (*domain.APIKey, error) {
+	ctx, span := tracer.Start(ctx, "apiKeyRepository.GetActiveByHash")
+	defer span.End()
+
+	var row struct {
+		domain.APIKey
+		Roles pq.StringArray `db:"roles"`
+	}
+	query := `SELECT id, name, key_hash, roles FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+	err := querier.GetContext(ctx, &row, query, keyHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	key := row.APIKey
+	key.Roles = toRoles(row.Roles)
+
+	key.AccountIDs = []int{}
+	query = `SELECT account_id FROM api_key_accounts WHERE api_key_id = $1 ORDER BY account_id`
+	if err := querier.SelectContext(ctx, &key.AccountIDs, query, key.ID); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) GrantAccount(ctx context.Context, querier db.Querier, apiKeyID int, accountID int) error {
+	ctx, span := tracer.Start(ctx, "apiKeyRepository.GrantAccount")
+	defer span.End()
+
+	query := `INSERT INTO api_key_accounts (api_key_id, account_id) VALUES ($1, $2)
+			  ON CONFLICT (api_key_id, account_id) DO NOTHING`
+	_, err := querier.ExecContext(ctx, query, apiKeyID, accountID)
+	return err
+}
+
+func toRoles(names []string) []domain.Role {
+	roles := make([]domain.Role, len(names))
+	for i, name := range names {
+		roles[i] = domain.Role(name)
+	}
+	return roles
+}
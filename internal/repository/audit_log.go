@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type auditLogRepository struct{}
+
+func NewAuditLogRepository() AuditLogRepository {
+	return &auditLogRepository{}
+}
+
+// auditRow is an audit_log row; roles are scanned as a string array.
+type auditRow struct {
+	domain.AuditEntry
+	Roles pq.StringArray `db:"roles"`
+}
+
+func (r *auditLogRepository) Create(ctx context.Context, querier db.Querier, entry *domain.AuditEntry) error {
+	ctx, span := tracer.Start(ctx, "auditLogRepository.Create")
+	defer span.End()
+
+	roles := make([]string, len(entry.Roles))
+	for i, role := range entry.Roles {
+		roles[i] = string(role)
+	}
+
+	query := `INSERT INTO audit_log (actor, api_key_id, roles, method, path, status_code, request_id)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	var created struct {
+		ID        int       `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	err := querier.GetContext(ctx, &created, query, entry.Actor, entry.APIKeyID, pq.Array(roles), entry.Method,
+		entry.Path, entry.StatusCode, entry.RequestID)
+	if err != nil {
+		return err
+	}
+
+	entry.ID = created.ID
+	entry.CreatedAt = created.CreatedAt
+	return nil
+}
+
+// UpdateStatus sets the status of an entry that has none yet.
+func (r *auditLogRepository) UpdateStatus(ctx context.Context, querier db.Querier, id int, status int) error {
+	ctx, span := tracer.Start(ctx, "auditLogRepository.UpdateStatus")
+	defer span.End()
+
+	query := `UPDATE audit_log SET status_code = $1 WHERE id = $2 AND status_code = 0`
+	_, err := querier.ExecContext(ctx, query, status, id)
+	return err
+}
+
+// List returns up to filter.Limit entries matching the filter, newest first,
+// starting before filter.Before.
+func (r *auditLogRepository) List(ctx context.Context, querier db.Querier, filter *domain.AuditFilter) ([]*domain.AuditEntry, error) {
+	ctx, span := tracer.Start(ctx, "auditLogRepository.List")
+	defer span.End()
+
+	query := `SELECT id, actor, api_key_id, roles, method, path, status_code, request_id, created_at
+			  FROM audit_log WHERE TRUE`
+	args := []interface{}{}
+
+	if filter.Actor != "" {
+		args = append(args, filter.Actor)
+		query += fmt.Sprintf(" AND actor = $%d", len(args))
+	}
+	if filter.Before != nil {
+		args = append(args, filter.Before.ID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows := []*auditRow{}
+	err := querier.SelectContext(ctx, &rows, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*domain.AuditEntry, len(rows))
+	for i, row := range rows {
+		entry := row.AuditEntry
+		entry.Roles = toRoles(row.Roles)
+		entries[i] = &entry
+	}
+	return entries, nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+)
+
+type cashMovementRepository struct{}
+
+func NewCashMovementRepository() CashMovementRepository {
+	return &cashMovementRepository{}
+}
+
+func (r *cashMovementRepository) Create(ctx context.Context, querier db.Querier, movement *domain.CashMovement) (*domain.CashMovement, error) {
+	ctx, span := tracer.Start(ctx, "cashMovementRepository.Create")
+	defer span.End()
+
+	query := `INSERT INTO cash_movements (account_id, type, amount, external_reference, status)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	var id int
+	err := querier.GetContext(ctx, &id, query, movement.AccountID, movement.Type, movement.Amount, movement.ExternalReference, movement.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, querier, id)
+}
+
+func (r *cashMovementRepository) GetByID(ctx context.Context, querier db.Querier, id int) (*domain.CashMovement, error) {
+	ctx, span := tracer.Start(ctx, "cashMovementRepository.GetByID")
+	defer span.End()
+
+	var movement domain.CashMovement
+	query := `SELECT id, account_id, type, amount, external_reference, status, failure_reason, created_at, updated_at
+			  FROM cash_movements WHERE id = $1`
+	err := querier.GetContext(ctx, &movement, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+func (r *cashMovementRepository) GetByExternalReference(ctx context.Context, querier db.Querier, accountID int, movementType domain.CashMovementType, reference string) (*domain.CashMovement, error) {
+	ctx, span := tracer.Start(ctx, "cashMovementRepository.GetByExternalReference")
+	defer span.End()
+
+	var movement domain.CashMovement
+	query := `SELECT id, account_id, type, amount, external_reference, status, failure_reason, created_at, updated_at
+			  FROM cash_movements WHERE account_id = $1 AND type = $2 AND external_reference = $3`
+	err := querier.GetContext(ctx, &movement, query, accountID, movementType, reference)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &movement, nil
+}
+
+func (r *cashMovementRepository) GetByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.CashMovement, error) {
+	ctx, span := tracer.Start(ctx, "cashMovementRepository.GetByAccountID")
+	defer span.End()
+
+	movements := []*domain.CashMovement{}
+	query := `SELECT id, account_id, type, amount, external_reference, status, failure_reason, created_at, updated_at
+			  FROM cash_movements WHERE account_id = $1 ORDER BY created_at DESC, id DESC`
+	err := querier.SelectContext(ctx, &movements, query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+func (r *cashMovementRepository) UpdateStatus(ctx context.Context, querier db.Querier, id int, from, to domain.CashMovementStatus, failureReason string) error {
+	ctx, span := tracer.Start(ctx, "cashMovementRepository.UpdateStatus")
+	defer span.End()
+
+	if err := from.TransitionTo(to); err != nil {
+		return err
+	}
+
+	query := `UPDATE cash_movements SET status = $1, failure_reason = $2, updated_at = NOW() WHERE id = $3 AND status = $4`
+	result, err := querier.ExecContext(ctx, query, to, failureReason, id, from)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrCashMovementStateConflict
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+)
+
+type holdRepository struct{}
+
+func NewHoldRepository() HoldRepository {
+	return &holdRepository{}
+}
+
+func (r *holdRepository) Create(ctx context.Context, querier db.Querier, hold *domain.Hold) (*domain.Hold, error) {
+	ctx, span := tracer.Start(ctx, "holdRepository.Create")
+	defer span.End()
+
+	query := `INSERT INTO holds (order_id, cash_movement_id, account_id, kind, stock_code, amount, quantity, remaining_amount, remaining_quantity, status)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $6, $7, 'ACTIVE') RETURNING id`
+
+	var id int
+	err := querier.GetContext(ctx, &id, query, hold.OrderID, hold.CashMovementID, hold.AccountID, hold.Kind, hold.StockCode, hold.Amount, hold.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.getByID(ctx, querier, id)
+}
+
+func (r *holdRepository) GetByOrderID(ctx context.Context, querier db.Querier, orderID int) (*domain.Hold, error) {
+	ctx, span := tracer.Start(ctx, "holdRepository.GetByOrderID")
+	defer span.End()
+
+	var hold domain.Hold
+	query := `SELECT id, order_id, cash_movement_id, account_id, kind, stock_code, amount, quantity, remaining_amount, remaining_quantity, status, created_at, updated_at
+			  FROM holds WHERE order_id = $1`
+	err := querier.GetContext(ctx, &hold, query, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *holdRepository) GetByCashMovementID(ctx context.Context, querier db.Querier, cashMovementID int) (*domain.Hold, error) {
+	ctx, span := tracer.Start(ctx, "holdRepository.GetByCashMovementID")
+	defer span.End()
+
+	var hold domain.Hold
+	query := `SELECT id, order_id, cash_movement_id, account_id, kind, stock_code, amount, quantity, remaining_amount, remaining_quantity, status, created_at, updated_at
+			  FROM holds WHERE cash_movement_id = $1`
+	err := querier.GetContext(ctx, &hold, query, cashMovementID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *holdRepository) GetActiveByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.Hold, error) {
+	ctx, span := tracer.Start(ctx, "holdRepository.GetActiveByAccountID")
+	defer span.End()
+
+	var holds []*domain.Hold
+	query := `SELECT id, order_id, cash_movement_id, account_id, kind, stock_code, amount, quantity, remaining_amount, remaining_quantity, status, created_at, updated_at
+			  FROM holds WHERE account_id = $1 AND status = 'ACTIVE'`
+	err := querier.SelectContext(ctx, &holds, query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+func (r *holdRepository) GetReservedCash(ctx context.Context, querier db.Querier, accountID int) (domain.Money, error) {
+	ctx, span := tracer.Start(ctx, "holdRepository.GetReservedCash")
+	defer span.End()
+
+	var reserved domain.Money
+	query := `SELECT COALESCE(SUM(remaining_amount), 0) FROM holds WHERE account_id = $1 AND kind = 'CASH' AND status = 'ACTIVE'`
+	err := querier.GetContext(ctx, &reserved, query, accountID)
+	return reserved, err
+}
+
+func (r *holdRepository) GetReservedQuantity(ctx context.Context, querier db.Querier, accountID int, stockCode string) (int, error) {
+	ctx, span := tracer.Start(ctx, "holdRepository.GetReservedQuantity")
+	defer span.End()
+
+	var reserved int
+	query := `SELECT COALESCE(SUM(remaining_quantity), 0) FROM holds
+			  WHERE account_id = $1 AND stock_code = $2 AND kind = 'SECURITY' AND status = 'ACTIVE'`
+	err := querier.GetContext(ctx, &reserved, query, accountID, stockCode)
+	return reserved, err
+}
+
+func (r *holdRepository) UpdateRemaining(ctx context.Context, querier db.Querier, id int, remainingAmount domain.Money, remainingQuantity int, status string) error {
+	ctx, span := tracer.Start(ctx, "holdRepository.UpdateRemaining")
+	defer span.End()
+
+	query := `UPDATE holds SET remaining_amount = $1, remaining_quantity = $2, status = $3, updated_at = NOW() WHERE id = $4`
+	_, err := querier.ExecContext(ctx, query, remainingAmount, remainingQuantity, status, id)
+	return err
+}
+
+// Resize grows or shrinks a hold by the given deltas, both what was held and
+// what remains of it.
+func (r *holdRepository) Resize(ctx context.Context, querier db.Querier, id int, amountDelta domain.Money, quantityDelta int) error {
+	ctx, span := tracer.Start(ctx, "holdRepository.Resize")
+	defer span.End()
+
+	query := `UPDATE holds SET amount = amount + $1, remaining_amount = remaining_amount + $1,
+			  quantity = quantity + $2, remaining_quantity = remaining_quantity + $2, updated_at = NOW()
+			  WHERE id = $3`
+	_, err := querier.ExecContext(ctx, query, amountDelta, quantityDelta, id)
+	return err
+}
+
+func (r *holdRepository) getByID(ctx context.Context, querier db.Querier, id int) (*domain.Hold, error) {
+	ctx, span := tracer.Start(ctx, "holdRepository.getByID")
+	defer span.End()
+
+	var hold domain.Hold
+	query := `SELECT id, order_id, cash_movement_id, account_id, kind, stock_code, amount, quantity, remaining_amount, remaining_quantity, status, created_at, updated_at
+			  FROM holds WHERE id = $1`
+	err := querier.GetContext(ctx, &hold, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"mini-ledger/internal/db"
 	"mini-ledger/internal/domain"
@@ -12,20 +13,26 @@ func NewHoldingRepository() HoldingRepository {
 	return &holdingRepository{}
 }
 
-func (r *holdingRepository) GetByAccountID(querier db.Querier, accountID int) ([]*domain.Holding, error) {
+func (r *holdingRepository) GetByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.Holding, error) {
+	ctx, span := tracer.Start(ctx, "holdingRepository.GetByAccountID")
+	defer span.End()
+
 	var holdings []*domain.Holding
 	query := `SELECT id, account_id, stock_code, quantity, created_at, updated_at FROM holdings WHERE account_id = $1`
-	err := querier.Select(&holdings, query, accountID)
+	err := querier.SelectContext(ctx, &holdings, query, accountID)
 	if err != nil {
 		return nil, err
 	}
 	return holdings, nil
 }
 
-func (r *holdingRepository) GetByAccountIDAndStockCode(querier db.Querier, accountID int, stockCode string) (*domain.Holding, error) {
+func (r *holdingRepository) GetByAccountIDAndStockCode(ctx context.Context, querier db.Querier, accountID int, stockCode string) (*domain.Holding, error) {
+	ctx, span := tracer.Start(ctx, "holdingRepository.GetByAccountIDAndStockCode")
+	defer span.End()
+
 	var holding domain.Holding
 	query := `SELECT id, account_id, stock_code, quantity, created_at, updated_at FROM holdings WHERE account_id = $1 AND stock_code = $2`
-	err := querier.Get(&holding, query, accountID, stockCode)
+	err := querier.GetContext(ctx, &holding, query, accountID, stockCode)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -35,21 +42,27 @@ func (r *holdingRepository) GetByAccountIDAndStockCode(querier db.Querier, accou
 	return &holding, nil
 }
 
-func (r *holdingRepository) UpdateQuantity(querier db.Querier, accountID int, stockCode string, quantity int) error {
+func (r *holdingRepository) UpdateQuantity(ctx context.Context, querier db.Querier, accountID int, stockCode string, quantity int) error {
+	ctx, span := tracer.Start(ctx, "holdingRepository.UpdateQuantity")
+	defer span.End()
+
 	if quantity <= 0 {
 		query := `DELETE FROM holdings WHERE account_id = $1 AND stock_code = $2`
-		_, err := querier.Exec(query, accountID, stockCode)
+		_, err := querier.ExecContext(ctx, query, accountID, stockCode)
 		return err
 	}
 
 	query := `UPDATE holdings SET quantity = $1, updated_at = NOW() WHERE account_id = $2 AND stock_code = $3`
-	_, err := querier.Exec(query, quantity, accountID, stockCode)
+	_, err := querier.ExecContext(ctx, query, quantity, accountID, stockCode)
 	return err
 }
 
-func (r *holdingRepository) Create(querier db.Querier, holding *domain.Holding) error {
+func (r *holdingRepository) Create(ctx context.Context, querier db.Querier, holding *domain.Holding) error {
+	ctx, span := tracer.Start(ctx, "holdingRepository.Create")
+	defer span.End()
+
 	query := `INSERT INTO holdings (account_id, stock_code, quantity) VALUES ($1, $2, $3) 
 			  ON CONFLICT (account_id, stock_code) DO UPDATE SET quantity = holdings.quantity + EXCLUDED.quantity`
-	_, err := querier.Exec(query, holding.AccountID, holding.StockCode, holding.Quantity)
+	_, err := querier.ExecContext(ctx, query, holding.AccountID, holding.StockCode, holding.Quantity)
 	return err
-}
\ No newline at end of file
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+)
+
+type idempotencyRepository struct{}
+
+func NewIdempotencyRepository() IdempotencyRepository {
+	return &idempotencyRepository{}
+}
+
+func (r *idempotencyRepository) Get(ctx context.Context, querier db.Querier, accountID int, key string) (*domain.IdempotencyRecord, error) {
+	ctx, span := tracer.Start(ctx, "idempotencyRepository.Get")
+	defer span.End()
+
+	var record domain.IdempotencyRecord
+	query := `SELECT account_id, idempotency_key, request_hash, status_code, response_body, created_at
+			  FROM idempotency_keys WHERE account_id = $1 AND idempotency_key = $2`
+	err := querier.GetContext(ctx, &record, query, accountID, key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepository) Create(ctx context.Context, querier db.Querier, record *domain.IdempotencyRecord) error {
+	ctx, span := tracer.Start(ctx, "idempotencyRepository.Create")
+	defer span.End()
+
+	query := `INSERT INTO idempotency_keys (account_id, idempotency_key, request_hash, status_code, response_body) VALUES ($1, $2, $3, $4, $5)`
+	_, err := querier.ExecContext(ctx, query, record.AccountID, record.Key, record.RequestHash, record.StatusCode, string(record.ResponseBody))
+	return err
+}
@@ -1,24 +1,123 @@
 package repository
 
 import (
+	"context"
 	"mini-ledger/internal/db"
 	"mini-ledger/internal/domain"
+
+	"go.opentelemetry.io/otel"
 )
 
+// tracer traces every repository call. otelsql traces the statements a call
+// runs beneath its span.
+var tracer = otel.Tracer("mini-ledger/internal/repository")
+
+// AccountRepository moves accounts between statuses only along the
+// transition table in the domain package, conditionally on the status the
+// caller read, like OrderRepository.
 type AccountRepository interface {
-	GetByID(querier db.Querier, id int) (*domain.Account, error)
-	UpdateBalance(querier db.Querier, id int, balance float64) error
+	Create(ctx context.Context, querier db.Querier, account *domain.Account) (*domain.Account, error)
+	GetByID(ctx context.Context, querier db.Querier, id int) (*domain.Account, error)
+	GetForUpdate(ctx context.Context, querier db.Querier, ids ...int) ([]*domain.Account, error)
+	List(ctx context.Context, querier db.Querier, filter *domain.AccountFilter) ([]*domain.Account, error)
+	NextNumberSequence(ctx context.Context, querier db.Querier) (int64, error)
+	UpdateStatus(ctx context.Context, querier db.Querier, id int, from, to domain.AccountStatus) error
 }
 
 type HoldingRepository interface {
-	GetByAccountID(querier db.Querier, accountID int) ([]*domain.Holding, error)
-	GetByAccountIDAndStockCode(querier db.Querier, accountID int, stockCode string) (*domain.Holding, error)
-	UpdateQuantity(querier db.Querier, accountID int, stockCode string, quantity int) error
-	Create(querier db.Querier, holding *domain.Holding) error
+	GetByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.Holding, error)
+	GetByAccountIDAndStockCode(ctx context.Context, querier db.Querier, accountID int, stockCode string) (*domain.Holding, error)
+	UpdateQuantity(ctx context.Context, querier db.Querier, accountID int, stockCode string, quantity int) error
+	Create(ctx context.Context, querier db.Querier, holding *domain.Holding) error
 }
 
+// OrderRepository moves orders between statuses only along the transition
+// table in the domain package, and only when the order is still in the
+// status the caller read: a status update that finds the order in another
+// status fails with domain.ErrOrderStateConflict.
 type OrderRepository interface {
-	Create(querier db.Querier, order *domain.Order) (*domain.Order, error)
-	GetByID(querier db.Querier, id int) (*domain.Order, error)
-	UpdateStatus(querier db.Querier, id int, status string) error
-}
\ No newline at end of file
+	Create(ctx context.Context, querier db.Querier, order *domain.Order) (*domain.Order, error)
+	GetByID(ctx context.Context, querier db.Querier, id int) (*domain.Order, error)
+	List(ctx context.Context, querier db.Querier, filter *domain.OrderFilter) ([]*domain.Order, error)
+	UpdateStatus(ctx context.Context, querier db.Querier, id int, from, to domain.OrderStatus) error
+	Trigger(ctx context.Context, querier db.Querier, id int, from domain.OrderStatus) error
+	UpdateFill(ctx context.Context, querier db.Querier, id int, filledQuantity int, from, to domain.OrderStatus) error
+	Amend(ctx context.Context, querier db.Querier, id int, status domain.OrderStatus, price domain.Money, quantity int, keepPriority bool) error
+	GetCrossingOrders(ctx context.Context, querier db.Querier, stockCode string, direction domain.Direction, price domain.Money) ([]*domain.Order, error)
+	GetBestPrice(ctx context.Context, querier db.Querier, stockCode string, direction domain.Direction) (*domain.Money, error)
+	GetExpiredIDs(ctx context.Context, querier db.Querier, limit int) ([]int, error)
+	GetTriggeredStops(ctx context.Context, querier db.Querier, stockCode string, lastPrice domain.Money) ([]*domain.Order, error)
+}
+
+// OrderEventRepository is append-only: every change of an order's status or
+// quantities is recorded as a new event.
+type OrderEventRepository interface {
+	Create(ctx context.Context, querier db.Querier, event *domain.OrderEvent) error
+	GetByOrderID(ctx context.Context, querier db.Querier, orderID int) ([]*domain.OrderEvent, error)
+}
+
+type TradeRepository interface {
+	Create(ctx context.Context, querier db.Querier, trade *domain.Trade) (*domain.Trade, error)
+	GetByOrderID(ctx context.Context, querier db.Querier, orderID int) ([]*domain.Trade, error)
+	GetByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.Trade, error)
+	GetLastPrice(ctx context.Context, querier db.Querier, stockCode string) (*domain.Money, error)
+}
+
+// JournalRepository is the only writer of accounts.balance: posting an entry
+// applies its CASH postings to the balance projection in the same statement
+// batch, so the balance always equals the sum of the account's CASH postings.
+type JournalRepository interface {
+	Post(ctx context.Context, querier db.Querier, entry *domain.JournalEntry) (*domain.JournalEntry, error)
+	GetUnbalancedEntryIDs(ctx context.Context, querier db.Querier) ([]int, error)
+	GetBalanceMismatches(ctx context.Context, querier db.Querier) ([]*domain.BalanceMismatch, error)
+}
+
+type HoldRepository interface {
+	Create(ctx context.Context, querier db.Querier, hold *domain.Hold) (*domain.Hold, error)
+	GetByOrderID(ctx context.Context, querier db.Querier, orderID int) (*domain.Hold, error)
+	GetByCashMovementID(ctx context.Context, querier db.Querier, cashMovementID int) (*domain.Hold, error)
+	GetActiveByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.Hold, error)
+	GetReservedCash(ctx context.Context, querier db.Querier, accountID int) (domain.Money, error)
+	GetReservedQuantity(ctx context.Context, querier db.Querier, accountID int, stockCode string) (int, error)
+	UpdateRemaining(ctx context.Context, querier db.Querier, id int, remainingAmount domain.Money, remainingQuantity int, status string) error
+	Resize(ctx context.Context, querier db.Querier, id int, amountDelta domain.Money, quantityDelta int) error
+}
+
+// CashMovementRepository moves cash movements from PENDING to a final status
+// conditionally on the status the caller read, like OrderRepository.
+type CashMovementRepository interface {
+	Create(ctx context.Context, querier db.Querier, movement *domain.CashMovement) (*domain.CashMovement, error)
+	GetByID(ctx context.Context, querier db.Querier, id int) (*domain.CashMovement, error)
+	GetByExternalReference(ctx context.Context, querier db.Querier, accountID int, movementType domain.CashMovementType, reference string) (*domain.CashMovement, error)
+	GetByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.CashMovement, error)
+	UpdateStatus(ctx context.Context, querier db.Querier, id int, from, to domain.CashMovementStatus, failureReason string) error
+}
+
+// TransferRepository is append-only: transfers are never updated.
+type TransferRepository interface {
+	Create(ctx context.Context, querier db.Querier, transfer *domain.Transfer) (*domain.Transfer, error)
+	GetByID(ctx context.Context, querier db.Querier, id int) (*domain.Transfer, error)
+	GetByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.Transfer, error)
+}
+
+type APIKeyRepository interface {
+	GetActiveByHash(ctx context.Context, querier db.Querier, keyHash string) (*domain.APIKey, error)
+	GrantAccount(ctx context.Context, querier db.Querier, apiKeyID int, accountID int) error
+}
+
+// AuditLogRepository is append-only: audit entries are never deleted, and only
+// the status of an entry is set, once, after it is created.
+type AuditLogRepository interface {
+	Create(ctx context.Context, querier db.Querier, entry *domain.AuditEntry) error
+	UpdateStatus(ctx context.Context, querier db.Querier, id int, status int) error
+	List(ctx context.Context, querier db.Querier, filter *domain.AuditFilter) ([]*domain.AuditEntry, error)
+}
+
+type RateLimitRepository interface {
+	Take(ctx context.Context, querier db.Querier, key string, rate float64, burst int) (bool, float64, error)
+}
+
+type IdempotencyRepository interface {
+	Get(ctx context.Context, querier db.Querier, accountID int, key string) (*domain.IdempotencyRecord, error)
+	Create(ctx context.Context, querier db.Querier, record *domain.IdempotencyRecord) error
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+)
+
+type journalRepository struct{}
+
+func NewJournalRepository() JournalRepository {
+	return &journalRepository{}
+}
+
+func (r *journalRepository) Post(ctx context.Context, querier db.Querier, entry *domain.JournalEntry) (*domain.JournalEntry, error) {
+	ctx, span := tracer.Start(ctx, "journalRepository.Post")
+	defer span.End()
+
+	var total domain.Money
+	for _, posting := range entry.Postings {
+		total += posting.Amount
+	}
+	if total != 0 || len(entry.Postings) == 0 {
+		return nil, domain.ErrUnbalancedEntry
+	}
+
+	query := `INSERT INTO journal_entries (type, order_id, trade_id, cash_movement_id, transfer_id, description)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := querier.GetContext(ctx, entry, query, entry.Type, entry.OrderID, entry.TradeID, entry.CashMovementID, entry.TransferID, entry.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, posting := range entry.Postings {
+		posting.EntryID = entry.ID
+		query := `INSERT INTO postings (entry_id, ledger, account_id, amount) VALUES ($1, $2, $3, $4)
+				  RETURNING id, created_at`
+		err := querier.GetContext(ctx, posting, query, posting.EntryID, posting.Ledger, posting.AccountID, posting.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		if posting.Ledger == domain.LedgerCash {
+			query := `UPDATE accounts SET balance = balance + $1, updated_at = NOW() WHERE id = $2`
+			if _, err := querier.ExecContext(ctx, query, posting.Amount, posting.AccountID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return entry, nil
+}
+
+func (r *journalRepository) GetUnbalancedEntryIDs(ctx context.Context, querier db.Querier) ([]int, error) {
+	ctx, span := tracer.Start(ctx, "journalRepository.GetUnbalancedEntryIDs")
+	defer span.End()
+
+	var ids []int
+	query := `SELECT entry_id FROM postings GROUP BY entry_id HAVING SUM(amount) <> 0 ORDER BY entry_id`
+	err := querier.SelectContext(ctx, &ids, query)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *journalRepository) GetBalanceMismatches(ctx context.Context, querier db.Querier) ([]*domain.BalanceMismatch, error) {
+	ctx, span := tracer.Start(ctx, "journalRepository.GetBalanceMismatches")
+	defer span.End()
+
+	var mismatches []*domain.BalanceMismatch
+	query := `SELECT a.id AS account_id, a.balance, COALESCE(p.total, 0) AS posted_balance
+			  FROM accounts a
+			  LEFT JOIN (SELECT account_id, SUM(amount) AS total FROM postings WHERE ledger = 'CASH' GROUP BY account_id) p
+			  ON p.account_id = a.id
+			  WHERE a.balance <> COALESCE(p.total, 0)
+			  ORDER BY a.id`
+	err := querier.SelectContext(ctx, &mismatches, query)
+	if err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
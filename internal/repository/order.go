@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"mini-ledger/internal/db"
 	"mini-ledger/internal/domain"
 )
@@ -11,33 +14,249 @@ func NewOrderRepository() OrderRepository {
 	return &orderRepository{}
 }
 
-func (r *orderRepository) Create(querier db.Querier, order *domain.Order) (*domain.Order, error) {
-	query := `INSERT INTO orders (account_id, stock_code, type, direction, quantity, price, filled_quantity, status) 
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-	
+func (r *orderRepository) Create(ctx context.Context, querier db.Querier, order *domain.Order) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "orderRepository.Create")
+	defer span.End()
+
+	query := `INSERT INTO orders (account_id, stock_code, type, direction, quantity, price, stop_price, filled_quantity, status, time_in_force, expires_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+
 	var id int
-	err := querier.Get(&id, query, order.AccountID, order.StockCode, order.Type, order.Direction, 
-		order.Quantity, order.Price, order.FilledQuantity, order.Status)
+	err := querier.GetContext(ctx, &id, query, order.AccountID, order.StockCode, order.Type, order.Direction,
+		order.Quantity, order.Price, order.StopPrice, order.FilledQuantity, order.Status, order.TimeInForce, order.ExpiresAt)
 	if err != nil {
 		return nil, err
 	}
-	
-	return r.GetByID(querier, id)
+
+	return r.GetByID(ctx, querier, id)
 }
 
-func (r *orderRepository) GetByID(querier db.Querier, id int) (*domain.Order, error) {
+func (r *orderRepository) GetByID(ctx context.Context, querier db.Querier, id int) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "orderRepository.GetByID")
+	defer span.End()
+
 	var order domain.Order
-	query := `SELECT id, account_id, stock_code, type, direction, quantity, price, filled_quantity, status, created_at, updated_at 
+	query := `SELECT id, account_id, stock_code, type, direction, quantity, price, stop_price, filled_quantity, status, time_in_force, expires_at, priority_at, created_at, updated_at 
 			  FROM orders WHERE id = $1`
-	err := querier.Get(&order, query, id)
+	err := querier.GetContext(ctx, &order, query, id)
 	if err != nil {
 		return nil, err
 	}
 	return &order, nil
 }
 
-func (r *orderRepository) UpdateStatus(querier db.Querier, id int, status string) error {
-	query := `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2`
-	_, err := querier.Exec(query, status, id)
-	return err
-}
\ No newline at end of file
+// List returns up to filter.Limit orders of an account matching the filter,
+// newest first, starting after filter.After.
+func (r *orderRepository) List(ctx context.Context, querier db.Querier, filter *domain.OrderFilter) ([]*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "orderRepository.List")
+	defer span.End()
+
+	query := `SELECT id, account_id, stock_code, type, direction, quantity, price, stop_price, filled_quantity, status, time_in_force, expires_at, priority_at, created_at, updated_at 
+			  FROM orders WHERE account_id = $1`
+	args := []interface{}{filter.AccountID}
+
+	where := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		query += " AND " + fmt.Sprintf(condition, placeholders...)
+	}
+
+	if filter.Status != "" {
+		where("status = %s", filter.Status)
+	}
+	if filter.Direction != "" {
+		where("direction = %s", filter.Direction)
+	}
+	if filter.StockCode != "" {
+		where("stock_code = %s", filter.StockCode)
+	}
+	if filter.CreatedFrom != nil {
+		where("created_at >= %s", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		where("created_at < %s", *filter.CreatedTo)
+	}
+	if filter.After != nil {
+		where("(created_at, id) < (%s, %s)", filter.After.CreatedAt, filter.After.ID)
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	orders := []*domain.Order{}
+	err := querier.SelectContext(ctx, &orders, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *orderRepository) UpdateStatus(ctx context.Context, querier db.Querier, id int, from, to domain.OrderStatus) error {
+	ctx, span := tracer.Start(ctx, "orderRepository.UpdateStatus")
+	defer span.End()
+
+	if err := from.TransitionTo(to); err != nil {
+		return err
+	}
+
+	query := `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`
+	result, err := querier.ExecContext(ctx, query, to, id, from)
+	if err != nil {
+		return err
+	}
+	return expectTransition(result)
+}
+
+// Trigger moves a stop order that is still in the given status to PENDING.
+// The order enters the book now, so its time priority starts now too and it
+// queues behind the orders already resting at its price.
+func (r *orderRepository) Trigger(ctx context.Context, querier db.Querier, id int, from domain.OrderStatus) error {
+	ctx, span := tracer.Start(ctx, "orderRepository.Trigger")
+	defer span.End()
+
+	if err := from.TransitionTo(domain.OrderStatusPending); err != nil {
+		return err
+	}
+
+	query := `UPDATE orders SET status = $1, priority_at = NOW(), updated_at = NOW() WHERE id = $2 AND status = $3`
+	result, err := querier.ExecContext(ctx, query, domain.OrderStatusPending, id, from)
+	if err != nil {
+		return err
+	}
+	return expectTransition(result)
+}
+
+func (r *orderRepository) UpdateFill(ctx context.Context, querier db.Querier, id int, filledQuantity int, from, to domain.OrderStatus) error {
+	ctx, span := tracer.Start(ctx, "orderRepository.UpdateFill")
+	defer span.End()
+
+	if err := from.TransitionTo(to); err != nil {
+		return err
+	}
+
+	query := `UPDATE orders SET filled_quantity = $1, status = $2, updated_at = NOW() WHERE id = $3 AND status = $4`
+	result, err := querier.ExecContext(ctx, query, filledQuantity, to, id, from)
+	if err != nil {
+		return err
+	}
+	return expectTransition(result)
+}
+
+// Amend changes the price and quantity of an order that is still in the given
+// status. Unless keepPriority is set the order loses its time priority and
+// queues behind the orders already at its price.
+func (r *orderRepository) Amend(ctx context.Context, querier db.Querier, id int, status domain.OrderStatus, price domain.Money, quantity int, keepPriority bool) error {
+	ctx, span := tracer.Start(ctx, "orderRepository.Amend")
+	defer span.End()
+
+	query := `UPDATE orders SET price = $1, quantity = $2, updated_at = NOW(),
+			  priority_at = CASE WHEN $3 THEN priority_at ELSE NOW() END
+			  WHERE id = $4 AND status = $5`
+	result, err := querier.ExecContext(ctx, query, price, quantity, keepPriority, id, status)
+	if err != nil {
+		return err
+	}
+	return expectTransition(result)
+}
+
+// expectTransition reports a conditional status update that matched no row,
+// because another transaction moved the order first, as a conflict.
+func expectTransition(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.ErrOrderStateConflict
+	}
+	return nil
+}
+
+// GetCrossingOrders locks and returns the open orders on the given side of the
+// book whose price crosses the given limit price, best price first and by time
+// priority within a price level. Orders past their expiry are left out even if
+// the expiry worker has not expired them yet.
+func (r *orderRepository) GetCrossingOrders(ctx context.Context, querier db.Querier, stockCode string, direction domain.Direction, price domain.Money) ([]*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "orderRepository.GetCrossingOrders")
+	defer span.End()
+
+	var orders []*domain.Order
+	var query string
+	if direction == domain.DirectionSell {
+		query = `SELECT id, account_id, stock_code, type, direction, quantity, price, stop_price, filled_quantity, status, time_in_force, expires_at, priority_at, created_at, updated_at 
+				  FROM orders WHERE stock_code = $1 AND direction = 'SELL' AND status IN ('PENDING', 'PARTIAL') AND price <= $2
+				  AND (expires_at IS NULL OR expires_at > NOW())
+				  ORDER BY price ASC, priority_at ASC, id ASC FOR UPDATE`
+	} else {
+		query = `SELECT id, account_id, stock_code, type, direction, quantity, price, stop_price, filled_quantity, status, time_in_force, expires_at, priority_at, created_at, updated_at 
+				  FROM orders WHERE stock_code = $1 AND direction = 'BUY' AND status IN ('PENDING', 'PARTIAL') AND price >= $2
+				  AND (expires_at IS NULL OR expires_at > NOW())
+				  ORDER BY price DESC, priority_at ASC, id ASC FOR UPDATE`
+	}
+	err := querier.SelectContext(ctx, &orders, query, stockCode, price)
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetBestPrice returns the best price among the open orders on the given side
+// of the book, the lowest ask or the highest bid, or nil when that side is
+// empty.
+func (r *orderRepository) GetBestPrice(ctx context.Context, querier db.Querier, stockCode string, direction domain.Direction) (*domain.Money, error) {
+	ctx, span := tracer.Start(ctx, "orderRepository.GetBestPrice")
+	defer span.End()
+
+	var price *domain.Money
+	var query string
+	if direction == domain.DirectionSell {
+		query = `SELECT MIN(price) FROM orders WHERE stock_code = $1 AND direction = 'SELL' AND status IN ('PENDING', 'PARTIAL')
+				  AND (expires_at IS NULL OR expires_at > NOW())`
+	} else {
+		query = `SELECT MAX(price) FROM orders WHERE stock_code = $1 AND direction = 'BUY' AND status IN ('PENDING', 'PARTIAL')
+				  AND (expires_at IS NULL OR expires_at > NOW())`
+	}
+	err := querier.GetContext(ctx, &price, query, stockCode)
+	if err != nil {
+		return nil, err
+	}
+	return price, nil
+}
+
+// GetExpiredIDs returns up to limit open or untriggered orders whose expiry
+// has passed, earliest expiry first.
+func (r *orderRepository) GetExpiredIDs(ctx context.Context, querier db.Querier, limit int) ([]int, error) {
+	ctx, span := tracer.Start(ctx, "orderRepository.GetExpiredIDs")
+	defer span.End()
+
+	ids := []int{}
+	query := `SELECT id FROM orders WHERE expires_at <= NOW() AND status IN ('PENDING', 'PARTIAL', 'TRIGGER_PENDING')
+			  ORDER BY expires_at LIMIT $1`
+	err := querier.SelectContext(ctx, &ids, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetTriggeredStops locks and returns the untriggered, unexpired stop orders of
+// a stock code that a trade at lastPrice triggers, oldest first.
+func (r *orderRepository) GetTriggeredStops(ctx context.Context, querier db.Querier, stockCode string, lastPrice domain.Money) ([]*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "orderRepository.GetTriggeredStops")
+	defer span.End()
+
+	orders := []*domain.Order{}
+	query := `SELECT id, account_id, stock_code, type, direction, quantity, price, stop_price, filled_quantity, status, time_in_force, expires_at, priority_at, created_at, updated_at 
+			  FROM orders WHERE stock_code = $1 AND status = 'TRIGGER_PENDING'
+			  AND ((direction = 'BUY' AND stop_price <= $2) OR (direction = 'SELL' AND stop_price >= $2))
+			  AND (expires_at IS NULL OR expires_at > NOW())
+			  ORDER BY created_at, id FOR UPDATE`
+	err := querier.SelectContext(ctx, &orders, query, stockCode, lastPrice)
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+)
+
+type orderEventRepository struct{}
+
+func NewOrderEventRepository() OrderEventRepository {
+	return &orderEventRepository{}
+}
+
+func (r *orderEventRepository) Create(ctx context.Context, querier db.Querier, event *domain.OrderEvent) error {
+	ctx, span := tracer.Start(ctx, "orderEventRepository.Create")
+	defer span.End()
+
+	query := `INSERT INTO order_events (order_id, type, old_status, new_status, old_quantity, new_quantity,
+			  old_filled_quantity, new_filled_quantity, trade_id, actor, reason)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := querier.ExecContext(ctx, query, event.OrderID, event.Type, event.OldStatus, event.NewStatus, event.OldQuantity,
+		event.NewQuantity, event.OldFilledQuantity, event.NewFilledQuantity, event.TradeID, event.Actor, event.Reason)
+	return err
+}
+
+func (r *orderEventRepository) GetByOrderID(ctx context.Context, querier db.Querier, orderID int) ([]*domain.OrderEvent, error) {
+	ctx, span := tracer.Start(ctx, "orderEventRepository.GetByOrderID")
+	defer span.End()
+
+	events := []*domain.OrderEvent{}
+	query := `SELECT id, order_id, type, old_status, new_status, old_quantity, new_quantity, old_filled_quantity,
+			  new_filled_quantity, trade_id, actor, reason, created_at
+			  FROM order_events WHERE order_id = $1 ORDER BY created_at, id`
+	err := querier.SelectContext(ctx, &events, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"mini-ledger/internal/db"
+)
+
+type rateLimitRepository struct{}
+
+func NewRateLimitRepository() RateLimitRepository {
+	return &rateLimitRepository{}
+}
+
+// Take refills the bucket for the time since it was last used, up to burst,
+// and takes a token from it if it then holds one. A bucket seen for the first
+// time starts full. It returns whether a token was taken and the tokens left.
+//
+// The refill and the take are one statement, so requests on different
+// replicas never take the same token. Every SET expression reads the row as
+// it was before the update, which is why the refill is spelled out three
+// times.
+func (r *rateLimitRepository) Take(ctx context.Context, querier db.Querier, key string, rate float64, burst int) (bool, float64, error) {
+	ctx, span := tracer.Start(ctx, "rateLimitRepository.Take")
+	defer span.End()
+
+	query := `INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+			  VALUES ($1, $2::FLOAT8 - 1, true, NOW())
+			  ON CONFLICT (key) DO UPDATE SET
+				  allowed = LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 * $3::FLOAT8) >= 1,
+				  tokens = LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 * $3::FLOAT8)
+					  - CASE WHEN LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 * $3::FLOAT8) >= 1 THEN 1 ELSE 0 END,
+				  updated_at = NOW()
+			  RETURNING allowed, tokens`
+
+	var result struct {
+		Allowed bool    `db:"allowed"`
+		Tokens  float64 `db:"tokens"`
+	}
+	err := querier.GetContext(ctx, &result, query, key, float64(burst), rate)
+	if err != nil {
+		return false, 0, err
+	}
+	return result.Allowed, result.Tokens, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+)
+
+type tradeRepository struct{}
+
+func NewTradeRepository() TradeRepository {
+	return &tradeRepository{}
+}
+
+func (r *tradeRepository) Create(ctx context.Context, querier db.Querier, trade *domain.Trade) (*domain.Trade, error) {
+	ctx, span := tracer.Start(ctx, "tradeRepository.Create")
+	defer span.End()
+
+	query := `INSERT INTO trades (stock_code, buy_order_id, sell_order_id, buy_account_id, sell_account_id, price, quantity, aggressor_side)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	var id int
+	err := querier.GetContext(ctx, &id, query, trade.StockCode, trade.BuyOrderID, trade.SellOrderID, trade.BuyAccountID,
+		trade.SellAccountID, trade.Price, trade.Quantity, trade.AggressorSide)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.getByID(ctx, querier, id)
+}
+
+func (r *tradeRepository) GetByOrderID(ctx context.Context, querier db.Querier, orderID int) ([]*domain.Trade, error) {
+	ctx, span := tracer.Start(ctx, "tradeRepository.GetByOrderID")
+	defer span.End()
+
+	var trades []*domain.Trade
+	query := `SELECT id, stock_code, buy_order_id, sell_order_id, buy_account_id, sell_account_id, price, quantity, aggressor_side, executed_at
+			  FROM trades WHERE buy_order_id = $1 OR sell_order_id = $1 ORDER BY executed_at, id`
+	err := querier.SelectContext(ctx, &trades, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return trades, nil
+}
+
+func (r *tradeRepository) GetByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.Trade, error) {
+	ctx, span := tracer.Start(ctx, "tradeRepository.GetByAccountID")
+	defer span.End()
+
+	var trades []*domain.Trade
+	query := `SELECT id, stock_code, buy_order_id, sell_order_id, buy_account_id, sell_account_id, price, quantity, aggressor_side, executed_at
+			  FROM trades WHERE buy_account_id = $1 OR sell_account_id = $1 ORDER BY executed_at, id`
+	err := querier.SelectContext(ctx, &trades, query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return trades, nil
+}
+
+// GetLastPrice returns the price of the most recent trade of a stock code, or
+// nil when it has never traded.
+func (r *tradeRepository) GetLastPrice(ctx context.Context, querier db.Querier, stockCode string) (*domain.Money, error) {
+	ctx, span := tracer.Start(ctx, "tradeRepository.GetLastPrice")
+	defer span.End()
+
+	var price domain.Money
+	query := `SELECT price FROM trades WHERE stock_code = $1 ORDER BY executed_at DESC, id DESC LIMIT 1`
+	err := querier.GetContext(ctx, &price, query, stockCode)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &price, nil
+}
+
+func (r *tradeRepository) getByID(ctx context.Context, querier db.Querier, id int) (*domain.Trade, error) {
+	ctx, span := tracer.Start(ctx, "tradeRepository.getByID")
+	defer span.End()
+
+	var trade domain.Trade
+	query := `SELECT id, stock_code, buy_order_id, sell_order_id, buy_account_id, sell_account_id, price, quantity, aggressor_side, executed_at
+			  FROM trades WHERE id = $1`
+	err := querier.GetContext(ctx, &trade, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &trade, nil
+}
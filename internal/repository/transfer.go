@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+)
+
+type transferRepository struct{}
+
+func NewTransferRepository() TransferRepository {
+	return &transferRepository{}
+}
+
+func (r *transferRepository) Create(ctx context.Context, querier db.Querier, transfer *domain.Transfer) (*domain.Transfer, error) {
+	ctx, span := tracer.Start(ctx, "transferRepository.Create")
+	defer span.End()
+
+	query := `INSERT INTO transfers (from_account_id, to_account_id, kind, stock_code, amount, quantity, memo)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	var id int
+	err := querier.GetContext(ctx, &id, query, transfer.FromAccountID, transfer.ToAccountID, transfer.Kind,
+		transfer.StockCode, transfer.Amount, transfer.Quantity, transfer.Memo)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, querier, id)
+}
+
+func (r *transferRepository) GetByID(ctx context.Context, querier db.Querier, id int) (*domain.Transfer, error) {
+	ctx, span := tracer.Start(ctx, "transferRepository.GetByID")
+	defer span.End()
+
+	var transfer domain.Transfer
+	query := `SELECT id, from_account_id, to_account_id, kind, stock_code, amount, quantity, memo, created_at
+			  FROM transfers WHERE id = $1`
+	err := querier.GetContext(ctx, &transfer, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// GetByAccountID returns the transfers into and out of an account, newest
+// first.
+func (r *transferRepository) GetByAccountID(ctx context.Context, querier db.Querier, accountID int) ([]*domain.Transfer, error) {
+	ctx, span := tracer.Start(ctx, "transferRepository.GetByAccountID")
+	defer span.End()
+
+	transfers := []*domain.Transfer{}
+	query := `SELECT id, from_account_id, to_account_id, kind, stock_code, amount, quantity, memo, created_at
+			  FROM transfers WHERE from_account_id = $1 OR to_account_id = $1
+			  ORDER BY created_at DESC, id DESC`
+	err := querier.SelectContext(ctx, &transfers, query, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
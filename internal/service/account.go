@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+	"mini-ledger/internal/repository"
+)
+
+const (
+	defaultAccountPageSize = 50
+	maxAccountPageSize     = 200
+)
+
+// AccountService opens accounts and moves them between ACTIVE, FROZEN and
+// CLOSED. Cash and shares are moved by TradingService.
+type AccountService struct {
+	db          *db.Database
+	accountRepo repository.AccountRepository
+	holdingRepo repository.HoldingRepository
+	holdRepo    repository.HoldRepository
+	apiKeyRepo  repository.APIKeyRepository
+}
+
+func NewAccountService(
+	database *db.Database,
+	accountRepo repository.AccountRepository,
+	holdingRepo repository.HoldingRepository,
+	holdRepo repository.HoldRepository,
+	apiKeyRepo repository.APIKeyRepository,
+) *AccountService {
+	return &AccountService{
+		db:          database,
+		accountRepo: accountRepo,
+		holdingRepo: holdingRepo,
+		holdRepo:    holdRepo,
+		apiKeyRepo:  apiKeyRepo,
+	}
+}
+
+// CreateAccount opens an ACTIVE account with a zero balance under a newly
+// generated account number. An API key that opens an account is granted
+// access to it; a JWT only covers the accounts it was issued for.
+func (s *AccountService) CreateAccount(ctx context.Context, req *domain.CreateAccountRequest) (*domain.Account, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	var account *domain.Account
+	err := s.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+		sequence, err := s.accountRepo.NextNumberSequence(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		account, err = s.accountRepo.Create(ctx, tx, &domain.Account{
+			AccountNumber: domain.NewAccountNumber(sequence),
+			Name:          strings.TrimSpace(req.Name),
+			Status:        domain.AccountStatusActive,
+		})
+		if err != nil {
+			return err
+		}
+
+		if principal := domain.PrincipalFromContext(ctx); principal != nil && principal.APIKeyID != nil {
+			return s.apiKeyRepo.GrantAccount(ctx, tx, *principal.APIKeyID, account.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *AccountService) GetAccount(ctx context.Context, accountID int) (*domain.Account, error) {
+	account, err := s.accountRepo.GetByID(ctx, s.db, accountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrAccountNotFound
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+// ListAccounts returns one page of accounts matching the filter. One extra
+// row is read to tell whether another page follows.
+func (s *AccountService) ListAccounts(ctx context.Context, filter *domain.AccountFilter) (*domain.AccountPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAccountPageSize
+	}
+	if limit > maxAccountPageSize {
+		limit = maxAccountPageSize
+	}
+
+	query := *filter
+	query.Limit = limit + 1
+	accounts, err := s.accountRepo.List(ctx, s.db, &query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.AccountPage{Accounts: accounts}
+	if len(accounts) > limit {
+		page.Accounts = accounts[:limit]
+		page.NextCursor = domain.IDCursor{ID: page.Accounts[limit-1].ID}.Encode()
+	}
+	return page, nil
+}
+
+// FreezeAccount stops an account from placing or amending orders.
+func (s *AccountService) FreezeAccount(ctx context.Context, accountID int) (*domain.Account, error) {
+	return s.changeStatus(ctx, accountID, domain.AccountStatusFrozen)
+}
+
+// UnfreezeAccount lets a frozen account trade again.
+func (s *AccountService) UnfreezeAccount(ctx context.Context, accountID int) (*domain.Account, error) {
+	return s.changeStatus(ctx, accountID, domain.AccountStatusActive)
+}
+
+// CloseAccount closes an account for good. The account must hold no cash, no
+// shares and no open orders.
+func (s *AccountService) CloseAccount(ctx context.Context, accountID int) (*domain.Account, error) {
+	return s.changeStatus(ctx, accountID, domain.AccountStatusClosed)
+}
+
+func (s *AccountService) changeStatus(ctx context.Context, accountID int, status domain.AccountStatus) (*domain.Account, error) {
+	var account *domain.Account
+	err := s.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+		current, err := s.accountRepo.GetByID(ctx, tx, accountID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return domain.ErrAccountNotFound
+			}
+			return err
+		}
+
+		if err := current.Status.TransitionTo(status); err != nil {
+			return err
+		}
+		if status == domain.AccountStatusClosed {
+			if err := s.checkEmpty(ctx, tx, current); err != nil {
+				return err
+			}
+		}
+
+		if err := s.accountRepo.UpdateStatus(ctx, tx, accountID, current.Status, status); err != nil {
+			return err
+		}
+
+		account, err = s.accountRepo.GetByID(ctx, tx, accountID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// checkEmpty returns domain.ErrAccountNotEmpty unless the account has no
+// cash, no shares and no active holds, that is no open orders.
+func (s *AccountService) checkEmpty(ctx context.Context, tx db.Querier, account *domain.Account) error {
+	if !account.Balance.IsZero() {
+		return domain.ErrAccountNotEmpty
+	}
+
+	holdings, err := s.holdingRepo.GetByAccountID(ctx, tx, account.ID)
+	if err != nil {
+		return err
+	}
+	for _, holding := range holdings {
+		if holding.Quantity != 0 {
+			return domain.ErrAccountNotEmpty
+		}
+	}
+
+	holds, err := s.holdRepo.GetActiveByAccountID(ctx, tx, account.ID)
+	if err != nil {
+		return err
+	}
+	if len(holds) > 0 {
+		return domain.ErrAccountNotEmpty
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+	"mini-ledger/internal/repository"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// AuditService keeps the audit log of the /admin endpoints.
+type AuditService struct {
+	db           *db.Database
+	auditLogRepo repository.AuditLogRepository
+}
+
+func NewAuditService(database *db.Database, auditLogRepo repository.AuditLogRepository) *AuditService {
+	return &AuditService{
+		db:           database,
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+// Begin appends an entry for a request about to be served to the audit log.
+// Its status stays 0 until Finish records the one the request got.
+func (s *AuditService) Begin(ctx context.Context, entry *domain.AuditEntry) error {
+	entry.StatusCode = 0
+	return s.auditLogRepo.Create(ctx, s.db, entry)
+}
+
+// Finish records the status a request got on its audit entry.
+func (s *AuditService) Finish(ctx context.Context, entry *domain.AuditEntry, status int) error {
+	if err := s.auditLogRepo.UpdateStatus(ctx, s.db, entry.ID, status); err != nil {
+		return err
+	}
+	entry.StatusCode = status
+	return nil
+}
+
+// ListAuditLog returns one page of the audit log matching the filter, newest
+// first. One extra row is read to tell whether another page follows.
+func (s *AuditService) ListAuditLog(ctx context.Context, filter *domain.AuditFilter) (*domain.AuditPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditPageSize
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+
+	query := *filter
+	query.Limit = limit + 1
+	entries, err := s.auditLogRepo.List(ctx, s.db, &query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = domain.IDCursor{ID: page.Entries[limit-1].ID}.Encode()
+	}
+	return page, nil
+}
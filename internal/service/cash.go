@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+	"mini-ledger/internal/repository"
+)
+
+// CashService moves cash into and out of accounts through external bank
+// rails. A deposit credits the account once it completes; a withdrawal holds
+// the cash while it is pending and debits it once it completes.
+type CashService struct {
+	db               *db.Database
+	accountRepo      repository.AccountRepository
+	cashMovementRepo repository.CashMovementRepository
+	journalRepo      repository.JournalRepository
+	holdRepo         repository.HoldRepository
+}
+
+func NewCashService(
+	database *db.Database,
+	accountRepo repository.AccountRepository,
+	cashMovementRepo repository.CashMovementRepository,
+	journalRepo repository.JournalRepository,
+	holdRepo repository.HoldRepository,
+) *CashService {
+	return &CashService{
+		db:               database,
+		accountRepo:      accountRepo,
+		cashMovementRepo: cashMovementRepo,
+		journalRepo:      journalRepo,
+		holdRepo:         holdRepo,
+	}
+}
+
+// Deposit records a deposit into the account. Deposits are accepted into
+// frozen accounts but not into closed ones.
+func (s *CashService) Deposit(ctx context.Context, accountID int, req *domain.CashMovementRequest) (*domain.CashMovementResult, error) {
+	return s.createMovement(ctx, accountID, domain.CashMovementDeposit, req)
+}
+
+// Withdraw records a withdrawal from an active account of at most its
+// available, that is unreserved, cash.
+func (s *CashService) Withdraw(ctx context.Context, accountID int, req *domain.CashMovementRequest) (*domain.CashMovementResult, error) {
+	return s.createMovement(ctx, accountID, domain.CashMovementWithdrawal, req)
+}
+
+// createMovement records a PENDING movement; only the bank rail, through
+// CompleteCashMovement or FailCashMovement, settles it. It is idempotent on
+// the external reference: a request with a reference the account already
+// used for the same type and amount returns the existing movement in its
+// current status, also when both requests arrive at the same time.
+func (s *CashService) createMovement(ctx context.Context, accountID int, movementType domain.CashMovementType, req *domain.CashMovementRequest) (*domain.CashMovementResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	reference := strings.TrimSpace(req.ExternalReference)
+
+	var result *domain.CashMovementResult
+	createOrReplay := func(ctx context.Context, tx db.Querier) error {
+		account, err := s.accountRepo.GetByID(ctx, tx, accountID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return domain.ErrAccountNotFound
+			}
+			return err
+		}
+
+		existing, err := s.cashMovementRepo.GetByExternalReference(ctx, tx, accountID, movementType, reference)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if existing.Amount != req.Amount {
+				return domain.ErrExternalReferenceReused
+			}
+			result = &domain.CashMovementResult{Movement: existing, Replayed: true}
+			return nil
+		}
+
+		if err := s.checkAccount(ctx, tx, account, movementType, req.Amount); err != nil {
+			return err
+		}
+
+		movement, err := s.cashMovementRepo.Create(ctx, tx, &domain.CashMovement{
+			AccountID:         accountID,
+			Type:              movementType,
+			Amount:            req.Amount,
+			ExternalReference: reference,
+			Status:            domain.CashMovementPending,
+		})
+		if err != nil {
+			return err
+		}
+
+		if movementType == domain.CashMovementWithdrawal {
+			_, err := s.holdRepo.Create(ctx, tx, &domain.Hold{
+				CashMovementID: &movement.ID,
+				AccountID:      accountID,
+				Kind:           domain.HoldKindCash,
+				Amount:         req.Amount,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		result = &domain.CashMovementResult{Movement: movement}
+		return nil
+	}
+	err := s.db.RunInTx(ctx, createOrReplay)
+	if db.IsUniqueViolation(err) {
+		// A concurrent request with the same reference created the movement
+		// first; running again replays it.
+		err = s.db.RunInTx(ctx, createOrReplay)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkAccount checks that the account may take the movement: a deposit
+// needs an account that is not closed, a withdrawal an active account with
+// enough available cash.
+func (s *CashService) checkAccount(ctx context.Context, tx db.Querier, account *domain.Account, movementType domain.CashMovementType, amount domain.Money) error {
+	if movementType == domain.CashMovementDeposit {
+		if account.Status == domain.AccountStatusClosed {
+			return domain.ErrAccountNotActive
+		}
+		return nil
+	}
+
+	if account.Status != domain.AccountStatusActive {
+		return domain.ErrAccountNotActive
+	}
+	reserved, err := s.holdRepo.GetReservedCash(ctx, tx, account.ID)
+	if err != nil {
+		return err
+	}
+	if account.Balance-reserved < amount {
+		return domain.ErrInsufficientFunds
+	}
+	return nil
+}
+
+func (s *CashService) GetCashMovement(ctx context.Context, movementID int) (*domain.CashMovement, error) {
+	movement, err := s.cashMovementRepo.GetByID(ctx, s.db, movementID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrCashMovementNotFound
+		}
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (s *CashService) GetAccountCashMovements(ctx context.Context, accountID int) ([]*domain.CashMovement, error) {
+	_, err := s.accountRepo.GetByID(ctx, s.db, accountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrAccountNotFound
+		}
+		return nil, err
+	}
+
+	return s.cashMovementRepo.GetByAccountID(ctx, s.db, accountID)
+}
+
+// CompleteCashMovement records that the bank rail confirmed a pending
+// movement and posts it to the journal. A deposit into an account that was
+// closed while it was pending fails with ErrAccountNotActive; the rail has to
+// fail it instead.
+func (s *CashService) CompleteCashMovement(ctx context.Context, movementID int) (*domain.CashMovement, error) {
+	var movement *domain.CashMovement
+	err := s.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+		pending, err := s.getPending(ctx, tx, movementID, domain.CashMovementCompleted)
+		if err != nil {
+			return err
+		}
+		if pending.Type == domain.CashMovementDeposit {
+			account, err := s.accountRepo.GetByID(ctx, tx, pending.AccountID)
+			if err != nil {
+				return err
+			}
+			if account.Status == domain.AccountStatusClosed {
+				return domain.ErrAccountNotActive
+			}
+		}
+		movement, err = s.completeMovement(ctx, tx, pending)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// FailCashMovement records that the bank rail rejected a pending movement.
+// A failed withdrawal releases the cash it held.
+func (s *CashService) FailCashMovement(ctx context.Context, movementID int, req *domain.FailCashMovementRequest) (*domain.CashMovement, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	var movement *domain.CashMovement
+	err := s.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+		pending, err := s.getPending(ctx, tx, movementID, domain.CashMovementFailed)
+		if err != nil {
+			return err
+		}
+
+		if err := s.releaseHold(ctx, tx, pending); err != nil {
+			return err
+		}
+		err = s.cashMovementRepo.UpdateStatus(ctx, tx, pending.ID, pending.Status, domain.CashMovementFailed, strings.TrimSpace(req.Reason))
+		if err != nil {
+			return err
+		}
+
+		movement, err = s.cashMovementRepo.GetByID(ctx, tx, pending.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// getPending reads a movement and checks that it may move to status, that
+// is that it is still pending.
+func (s *CashService) getPending(ctx context.Context, tx db.Querier, movementID int, status domain.CashMovementStatus) (*domain.CashMovement, error) {
+	movement, err := s.cashMovementRepo.GetByID(ctx, tx, movementID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrCashMovementNotFound
+		}
+		return nil, err
+	}
+	if err := movement.Status.TransitionTo(status); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// completeMovement posts a pending movement between the account's CASH ledger
+// and the EXTERNAL ledger and marks it COMPLETED. A withdrawal's hold is
+// released in the same transaction, so its cash moves from reserved to gone
+// without ever being available.
+func (s *CashService) completeMovement(ctx context.Context, tx db.Querier, movement *domain.CashMovement) (*domain.CashMovement, error) {
+	entryType, amount := domain.EntryDeposit, movement.Amount
+	if movement.Type == domain.CashMovementWithdrawal {
+		entryType, amount = domain.EntryWithdrawal, -movement.Amount
+	}
+
+	_, err := s.journalRepo.Post(ctx, tx, &domain.JournalEntry{
+		Type:           entryType,
+		CashMovementID: &movement.ID,
+		Description:    movement.ExternalReference,
+		Postings: []*domain.Posting{
+			accountPosting(domain.LedgerCash, movement.AccountID, amount),
+			housePosting(domain.LedgerExternal, -amount),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.releaseHold(ctx, tx, movement); err != nil {
+		return nil, err
+	}
+	if err := s.cashMovementRepo.UpdateStatus(ctx, tx, movement.ID, movement.Status, domain.CashMovementCompleted, ""); err != nil {
+		return nil, err
+	}
+
+	return s.cashMovementRepo.GetByID(ctx, tx, movement.ID)
+}
+
+// releaseHold releases the hold of a pending withdrawal. Deposits have none.
+func (s *CashService) releaseHold(ctx context.Context, tx db.Querier, movement *domain.CashMovement) error {
+	hold, err := s.holdRepo.GetByCashMovementID(ctx, tx, movement.ID)
+	if err != nil || hold == nil || hold.Status == domain.HoldStatusReleased {
+		return err
+	}
+	return s.holdRepo.UpdateRemaining(ctx, tx, hold.ID, 0, 0, domain.HoldStatusReleased)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mini-ledger/internal/config"
+)
+
+// ExpiryWorker periodically expires DAY and GTD orders whose expiry has
+// passed. Several replicas may run it at once: an order expired by one of
+// them is skipped by the others.
+type ExpiryWorker struct {
+	tradingService *TradingService
+	interval       time.Duration
+	batchSize      int
+	cancel         context.CancelFunc
+	done           chan struct{}
+}
+
+func NewExpiryWorker(cfg *config.Config, tradingService *TradingService) *ExpiryWorker {
+	return &ExpiryWorker{
+		tradingService: tradingService,
+		interval:       cfg.ExpiryInterval,
+		batchSize:      cfg.ExpiryBatchSize,
+	}
+}
+
+// Start runs the worker in the background until Stop is called.
+func (w *ExpiryWorker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.run(ctx)
+			}
+		}
+	}()
+}
+
+// Stop cancels the current run and waits for the worker to exit, or for ctx
+// to be done.
+func (w *ExpiryWorker) Stop(ctx context.Context) error {
+	w.cancel()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run expires due orders batch by batch until a batch comes back short. A run
+// is traced as one span, whose trace ID goes with any error it logs.
+func (w *ExpiryWorker) run(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "ExpiryWorker.run")
+	defer span.End()
+
+	for ctx.Err() == nil {
+		expired, err := w.tradingService.ExpireOrders(ctx, w.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("Order expiry error: %v trace_id=%s\n", err, span.SpanContext().TraceID())
+			}
+			return
+		}
+		if expired > 0 {
+			fmt.Printf("Expired %d orders\n", expired)
+		}
+		if expired < w.batchSize {
+			return
+		}
+	}
+}
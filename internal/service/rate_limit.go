@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"mini-ledger/internal/config"
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/repository"
+
+	"golang.org/x/time/rate"
+)
+
+// memorySweepInterval is how often the in-memory limiter drops full buckets.
+const memorySweepInterval = time.Minute
+
+// RateBudget is a token bucket: it holds up to Burst requests and refills at
+// Rate requests per second. Each key has its own bucket per budget.
+type RateBudget struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter takes a token for a request from the bucket of every key. When
+// any of the buckets is empty it takes nothing from any of them and returns
+// how long until they all hold a token again.
+type RateLimiter interface {
+	Allow(ctx context.Context, budget RateBudget, keys ...string) (bool, time.Duration, error)
+}
+
+// errRateLimited rolls back the tokens a shared limiter took for a request
+// one of whose buckets is empty.
+var errRateLimited = errors.New("rate limited")
+
+// NewRateLimiter returns the limiter RateLimitStore selects: buckets in the
+// memory of this replica, or buckets in CockroachDB shared by every replica.
+func NewRateLimiter(cfg *config.Config, database *db.Database, rateLimitRepo repository.RateLimitRepository) (RateLimiter, error) {
+	if cfg.OrderRateLimit <= 0 || cfg.OrderRateLimitBurst < 1 || cfg.ReadRateLimit <= 0 || cfg.ReadRateLimitBurst < 1 {
+		return nil, fmt.Errorf("rate limits need a positive rate and a burst of at least 1")
+	}
+
+	switch cfg.RateLimitStore {
+	case "memory":
+		return &memoryRateLimiter{buckets: map[string]*rate.Limiter{}, lastSweep: time.Now()}, nil
+	case "cockroach":
+		return &sharedRateLimiter{db: database, rateLimitRepo: rateLimitRepo}, nil
+	}
+	return nil, fmt.Errorf("unknown rate limit store %q", cfg.RateLimitStore)
+}
+
+// memoryRateLimiter keeps its buckets in memory. With several replicas each
+// replica enforces the budgets on its own share of the requests.
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+func (l *memoryRateLimiter) Allow(ctx context.Context, budget RateBudget, keys ...string) (bool, time.Duration, error) {
+	now := time.Now()
+	reservations := make([]*rate.Reservation, len(keys))
+	var delay time.Duration
+	for i, key := range keys {
+		reservations[i] = l.bucket(now, budget, key).ReserveN(now, 1)
+		delay = max(delay, reservations[i].DelayFrom(now))
+	}
+	if delay == 0 {
+		return true, 0, nil
+	}
+
+	for _, reservation := range reservations {
+		reservation.CancelAt(now)
+	}
+	return false, delay, nil
+}
+
+func (l *memoryRateLimiter) bucket(now time.Time, budget RateBudget, key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A full bucket behaves like one that does not exist yet, so dropping
+	// full buckets bounds memory without loosening any limit.
+	if now.Sub(l.lastSweep) >= memorySweepInterval {
+		for bucketKey, bucket := range l.buckets {
+			if bucket.TokensAt(now) >= float64(bucket.Burst()) {
+				delete(l.buckets, bucketKey)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucketKey := budget.Name + ":" + key
+	bucket, ok := l.buckets[bucketKey]
+	if !ok {
+		bucket = rate.NewLimiter(rate.Limit(budget.Rate), budget.Burst)
+		l.buckets[bucketKey] = bucket
+	}
+	return bucket
+}
+
+// sharedRateLimiter keeps its buckets in CockroachDB, so that the budgets
+// hold across replicas. Every request costs a transaction of one statement
+// per key, outside the request's transaction; it is rolled back when a
+// bucket is empty, so that the other buckets keep their tokens.
+type sharedRateLimiter struct {
+	db            *db.Database
+	rateLimitRepo repository.RateLimitRepository
+}
+
+func (l *sharedRateLimiter) Allow(ctx context.Context, budget RateBudget, keys ...string) (bool, time.Duration, error) {
+	var delay time.Duration
+	err := l.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+		delay = 0
+		for _, key := range keys {
+			allowed, tokens, err := l.rateLimitRepo.Take(ctx, tx, budget.Name+":"+key, budget.Rate, budget.Burst)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				seconds := (1 - tokens) / budget.Rate
+				delay = max(delay, time.Duration(math.Ceil(seconds*float64(time.Second))))
+			}
+		}
+		if delay > 0 {
+			return errRateLimited
+		}
+		return nil
+	})
+	if err == errRateLimited {
+		return false, delay, nil
+	}
+	return err == nil, 0, err
+}
@@ -1,10 +1,34 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mini-ledger/internal/config"
 	"mini-ledger/internal/db"
 	"mini-ledger/internal/domain"
+	"mini-ledger/internal/matching"
+	"mini-ledger/internal/metrics"
 	"mini-ledger/internal/repository"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// tracer traces the TradingService methods.
+var tracer = otel.Tracer("mini-ledger/internal/service")
+
+const (
+	defaultOrderPageSize = 50
+	maxOrderPageSize     = 200
 )
 
 type TradingService struct {
@@ -12,24 +36,54 @@ type TradingService struct {
 	accountRepo     repository.AccountRepository
 	holdingRepo     repository.HoldingRepository
 	orderRepo       repository.OrderRepository
+	orderEventRepo  repository.OrderEventRepository
+	tradeRepo       repository.TradeRepository
+	journalRepo     repository.JournalRepository
+	holdRepo        repository.HoldRepository
+	idempotencyRepo repository.IdempotencyRepository
+	metrics         *metrics.Metrics
+	sellFeeBps      int64
+	marketCollarBps int64
+	marketTimezone  *time.Location
+	dayOrderCutoff  config.ClockTime
 }
 
 func NewTradingService(
+	cfg *config.Config,
 	database *db.Database,
 	accountRepo repository.AccountRepository,
 	holdingRepo repository.HoldingRepository,
 	orderRepo repository.OrderRepository,
+	orderEventRepo repository.OrderEventRepository,
+	tradeRepo repository.TradeRepository,
+	journalRepo repository.JournalRepository,
+	holdRepo repository.HoldRepository,
+	idempotencyRepo repository.IdempotencyRepository,
+	metrics *metrics.Metrics,
 ) *TradingService {
 	return &TradingService{
-		db:          database,
-		accountRepo: accountRepo,
-		holdingRepo: holdingRepo,
-		orderRepo:   orderRepo,
+		db:              database,
+		accountRepo:     accountRepo,
+		holdingRepo:     holdingRepo,
+		orderRepo:       orderRepo,
+		orderEventRepo:  orderEventRepo,
+		tradeRepo:       tradeRepo,
+		journalRepo:     journalRepo,
+		holdRepo:        holdRepo,
+		idempotencyRepo: idempotencyRepo,
+		metrics:         metrics,
+		sellFeeBps:      cfg.SellFeeBps,
+		marketCollarBps: cfg.MarketCollarBps,
+		marketTimezone:  cfg.MarketTimezone.Location,
+		dayOrderCutoff:  cfg.DayOrderCutoff,
 	}
 }
 
-func (s *TradingService) GetAccountBalance(accountID int) (*domain.BalanceResponse, error) {
-	account, err := s.accountRepo.GetByID(s.db, accountID)
+func (s *TradingService) GetAccountBalance(ctx context.Context, accountID int) (*domain.BalanceResponse, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.GetAccountBalance")
+	defer span.End()
+
+	account, err := s.accountRepo.GetByID(ctx, s.db, accountID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, domain.ErrAccountNotFound
@@ -37,14 +91,24 @@ func (s *TradingService) GetAccountBalance(accountID int) (*domain.BalanceRespon
 		return nil, err
 	}
 
+	reserved, err := s.holdRepo.GetReservedCash(ctx, s.db, accountID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &domain.BalanceResponse{
 		AccountNumber: account.AccountNumber,
 		Balance:       account.Balance,
+		Available:     account.Balance - reserved,
+		Reserved:      reserved,
 	}, nil
 }
 
-func (s *TradingService) GetAccountHoldings(accountID int) ([]*domain.HoldingResponse, error) {
-	_, err := s.accountRepo.GetByID(s.db, accountID)
+func (s *TradingService) GetAccountHoldings(ctx context.Context, accountID int) ([]*domain.HoldingResponse, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.GetAccountHoldings")
+	defer span.End()
+
+	_, err := s.accountRepo.GetByID(ctx, s.db, accountID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, domain.ErrAccountNotFound
@@ -52,30 +116,58 @@ func (s *TradingService) GetAccountHoldings(accountID int) ([]*domain.HoldingRes
 		return nil, err
 	}
 
-	holdings, err := s.holdingRepo.GetByAccountID(s.db, accountID)
+	holdings, err := s.holdingRepo.GetByAccountID(ctx, s.db, accountID)
 	if err != nil {
 		return nil, err
 	}
 
+	holds, err := s.holdRepo.GetActiveByAccountID(ctx, s.db, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[string]int)
+	for _, hold := range holds {
+		if hold.Kind == domain.HoldKindSecurity {
+			reserved[hold.StockCode] += hold.RemainingQuantity
+		}
+	}
+
 	var response []*domain.HoldingResponse
 	for _, holding := range holdings {
 		response = append(response, &domain.HoldingResponse{
 			StockCode: holding.StockCode,
 			Quantity:  holding.Quantity,
+			Available: holding.Quantity - reserved[holding.StockCode],
+			Reserved:  reserved[holding.StockCode],
 		})
 	}
 
 	return response, nil
 }
 
-func (s *TradingService) CreateOrder(req *domain.CreateOrderRequest) (*domain.Order, error) {
-	tx, err := s.db.BeginTx()
+func (s *TradingService) GetAccountTrades(ctx context.Context, accountID int) ([]*domain.Trade, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.GetAccountTrades")
+	defer span.End()
+
+	_, err := s.accountRepo.GetByID(ctx, s.db, accountID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrAccountNotFound
+		}
 		return nil, err
 	}
-	defer tx.Rollback()
 
-	account, err := s.accountRepo.GetByID(tx, req.AccountID)
+	return s.tradeRepo.GetByAccountID(ctx, s.db, accountID)
+}
+
+// ListAccountOrders returns one page of the account's orders matching the
+// filter. One extra row is read to tell whether another page follows.
+func (s *TradingService) ListAccountOrders(ctx context.Context, filter *domain.OrderFilter) (*domain.OrderPage, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.ListAccountOrders")
+	defer span.End()
+
+	_, err := s.accountRepo.GetByID(ctx, s.db, filter.AccountID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, domain.ErrAccountNotFound
@@ -83,29 +175,220 @@ func (s *TradingService) CreateOrder(req *domain.CreateOrderRequest) (*domain.Or
 		return nil, err
 	}
 
-	if req.Direction == "BUY" {
-		totalCost := req.Price * float64(req.Quantity)
-		if account.Balance < totalCost {
-			return nil, domain.ErrInsufficientFunds
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultOrderPageSize
+	}
+	if limit > maxOrderPageSize {
+		limit = maxOrderPageSize
+	}
+
+	query := *filter
+	query.Limit = limit + 1
+	orders, err := s.orderRepo.List(ctx, s.db, &query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.NextCursor = domain.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return page, nil
+}
+
+func (s *TradingService) GetOrder(ctx context.Context, orderID int) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.GetOrder")
+	defer span.End()
+
+	order, err := s.orderRepo.GetByID(ctx, s.db, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrOrderNotFound
 		}
+		return nil, err
+	}
+	return order, nil
+}
 
-		newBalance := account.Balance - totalCost
-		if err := s.accountRepo.UpdateBalance(tx, req.AccountID, newBalance); err != nil {
-			return nil, err
+func (s *TradingService) GetOrderEvents(ctx context.Context, orderID int) ([]*domain.OrderEvent, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.GetOrderEvents")
+	defer span.End()
+
+	_, err := s.orderRepo.GetByID(ctx, s.db, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	return s.orderEventRepo.GetByOrderID(ctx, s.db, orderID)
+}
+
+func (s *TradingService) GetOrderTrades(ctx context.Context, orderID int) ([]*domain.Trade, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.GetOrderTrades")
+	defer span.End()
+
+	_, err := s.orderRepo.GetByID(ctx, s.db, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrOrderNotFound
 		}
-	} else if req.Direction == "SELL" {
-		holding, err := s.holdingRepo.GetByAccountIDAndStockCode(tx, req.AccountID, req.StockCode)
+		return nil, err
+	}
+
+	return s.tradeRepo.GetByOrderID(ctx, s.db, orderID)
+}
+
+// ReconcileLedger proves the journal against the balance projection: every
+// entry must sum to zero and every account balance must equal the sum of the
+// account's CASH postings.
+func (s *TradingService) ReconcileLedger(ctx context.Context) (*domain.ReconciliationResponse, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.ReconcileLedger")
+	defer span.End()
+
+	unbalanced, err := s.journalRepo.GetUnbalancedEntryIDs(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches, err := s.journalRepo.GetBalanceMismatches(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.ReconciliationResponse{
+		Balanced:          len(unbalanced) == 0 && len(mismatches) == 0,
+		UnbalancedEntries: unbalanced,
+		Mismatches:        mismatches,
+	}, nil
+}
+
+// CreateOrder creates and matches an order. When idempotencyKey is set, the
+// response is stored with the order in the same transaction, and a later
+// request from the same account with the same key gets that stored response
+// back instead of creating a second order, also when both requests arrive at
+// the same time. Reusing a key for a different request fails with
+// ErrIdempotencyKeyReused.
+func (s *TradingService) CreateOrder(ctx context.Context, req *domain.CreateOrderRequest, idempotencyKey string) (*domain.CreateOrderResult, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.CreateOrder")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return nil, domain.ErrInvalidIdempotencyKey
+	}
+
+	requestHash, err := hashRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *domain.CreateOrderResult
+	createOrReplay := func(ctx context.Context, tx db.Querier) error {
+		if idempotencyKey != "" {
+			record, err := s.idempotencyRepo.Get(ctx, tx, req.AccountID, idempotencyKey)
+			if err != nil {
+				return err
+			}
+			if record != nil {
+				result, err = replay(record, requestHash)
+				return err
+			}
+		}
+
+		order, err := s.createOrder(ctx, tx, req)
+		if err != nil {
+			return err
+		}
+		result = &domain.CreateOrderResult{Order: order, StatusCode: http.StatusCreated}
+
+		if idempotencyKey == "" {
+			return nil
+		}
+		body, err := json.Marshal(order)
+		if err != nil {
+			return err
+		}
+		return s.idempotencyRepo.Create(ctx, tx, &domain.IdempotencyRecord{
+			AccountID:    req.AccountID,
+			Key:          idempotencyKey,
+			RequestHash:  requestHash,
+			StatusCode:   result.StatusCode,
+			ResponseBody: body,
+		})
+	}
+	err = s.db.RunInTx(ctx, createOrReplay)
+	if idempotencyKey != "" && db.IsUniqueViolation(err) {
+		// A concurrent request with the same key stored its response first;
+		// running again replays it.
+		err = s.db.RunInTx(ctx, createOrReplay)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *TradingService) createOrder(ctx context.Context, tx db.Querier, req *domain.CreateOrderRequest) (*domain.Order, error) {
+	account, err := s.accountRepo.GetByID(ctx, tx, req.AccountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrAccountNotFound
+		}
+		return nil, err
+	}
+	if account.Status != domain.AccountStatusActive {
+		return nil, domain.ErrAccountNotActive
+	}
+
+	price := req.Price
+	if req.Type.IsMarket() {
+		price, err = s.marketLimitPrice(ctx, tx, req)
 		if err != nil {
 			return nil, err
 		}
-		if holding == nil || holding.Quantity < req.Quantity {
-			return nil, domain.ErrInsufficientHoldingQuantity
+		if err := req.ValidateLimitPrice(price); err != nil {
+			return nil, err
 		}
+	}
 
-		newQuantity := holding.Quantity - req.Quantity
-		if err := s.holdingRepo.UpdateQuantity(tx, req.AccountID, req.StockCode, newQuantity); err != nil {
+	hold := &domain.Hold{
+		AccountID: req.AccountID,
+		StockCode: req.StockCode,
+	}
+	if req.Direction == domain.DirectionBuy {
+		reserved, err := s.holdRepo.GetReservedCash(ctx, tx, req.AccountID)
+		if err != nil {
 			return nil, err
 		}
+
+		totalCost := price.MulInt(req.Quantity)
+		if account.Balance-reserved < totalCost {
+			return nil, domain.ErrInsufficientFunds
+		}
+		hold.Kind = domain.HoldKindCash
+		hold.Amount = totalCost
+	} else {
+		holding, err := s.holdingRepo.GetByAccountIDAndStockCode(ctx, tx, req.AccountID, req.StockCode)
+		if err != nil {
+			return nil, err
+		}
+		reserved, err := s.holdRepo.GetReservedQuantity(ctx, tx, req.AccountID, req.StockCode)
+		if err != nil {
+			return nil, err
+		}
+
+		if holding == nil || holding.Quantity-reserved < req.Quantity {
+			return nil, domain.ErrInsufficientHoldingQuantity
+		}
+		hold.Kind = domain.HoldKindSecurity
+		hold.Quantity = req.Quantity
 	}
 
 	order := &domain.Order{
@@ -114,31 +397,80 @@ func (s *TradingService) CreateOrder(req *domain.CreateOrderRequest) (*domain.Or
 		Type:           req.Type,
 		Direction:      req.Direction,
 		Quantity:       req.Quantity,
-		Price:          req.Price,
+		Price:          price,
 		FilledQuantity: 0,
-		Status:         "PENDING",
+		Status:         domain.OrderStatusPending,
+		TimeInForce:    req.EffectiveTimeInForce(),
+		ExpiresAt:      req.ExpiresAt,
+		StopPrice:      req.StopPrice,
+	}
+	if req.Type.IsStop() {
+		order.Status = domain.OrderStatusTriggerPending
+	}
+	if order.TimeInForce == domain.TimeInForceDay {
+		expiresAt := s.dayOrderExpiry(time.Now())
+		order.ExpiresAt = &expiresAt
 	}
 
-	createdOrder, err := s.orderRepo.Create(tx, order)
+	createdOrder, err := s.orderRepo.Create(ctx, tx, order)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
+	err = s.orderEventRepo.Create(ctx, tx, &domain.OrderEvent{
+		OrderID:     createdOrder.ID,
+		Type:        domain.OrderEventCreated,
+		NewStatus:   createdOrder.Status,
+		NewQuantity: createdOrder.Quantity,
+		Actor:       domain.AccountActor(createdOrder.AccountID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hold.OrderID = &createdOrder.ID
+	if _, err := s.holdRepo.Create(ctx, tx, hold); err != nil {
+		return nil, err
+	}
+
+	if createdOrder.Status == domain.OrderStatusPending {
+		if err := s.executeOrder(ctx, tx, createdOrder); err != nil {
+			return nil, err
+		}
+	}
+
+	// The new order's trades may have triggered stop orders; a new stop order
+	// is triggered right away if the last trade price has already reached it.
+	if err := s.triggerStops(ctx, tx, createdOrder.StockCode); err != nil {
+		return nil, err
+	}
+
+	matchedOrder, err := s.orderRepo.GetByID(ctx, tx, createdOrder.ID)
+	if err != nil {
 		return nil, err
 	}
 
-	return createdOrder, nil
+	return matchedOrder, nil
 }
 
-func (s *TradingService) CancelOrder(orderID int) (*domain.Order, error) {
-	tx, err := s.db.BeginTx()
+func (s *TradingService) CancelOrder(ctx context.Context, orderID int) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.CancelOrder")
+	defer span.End()
+
+	var order *domain.Order
+	err := s.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+		var err error
+		order, err = s.cancelOrder(ctx, tx, orderID)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback()
+	return order, nil
+}
 
-	order, err := s.orderRepo.GetByID(tx, orderID)
+func (s *TradingService) cancelOrder(ctx context.Context, tx db.Querier, orderID int) (*domain.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, tx, orderID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, domain.ErrOrderNotFound
@@ -146,59 +478,628 @@ func (s *TradingService) CancelOrder(orderID int) (*domain.Order, error) {
 		return nil, err
 	}
 
-	if order.Status != "PENDING" && order.Status != "PARTIAL" {
+	if err := order.Status.TransitionTo(domain.OrderStatusCanceled); err != nil {
 		return nil, domain.ErrOrderNotCancelable
 	}
 
-	unfilledQuantity := order.Quantity - order.FilledQuantity
+	err = s.closeOrder(ctx, tx, order, domain.OrderStatusCanceled, domain.OrderEventCanceled,
+		domain.AccountActor(order.AccountID), "canceled by account")
+	if err != nil {
+		return nil, err
+	}
 
-	if order.Direction == "BUY" {
-		refundAmount := order.Price * float64(unfilledQuantity)
-		account, err := s.accountRepo.GetByID(tx, order.AccountID)
-		if err != nil {
-			return nil, err
+	updatedOrder, err := s.orderRepo.GetByID(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return updatedOrder, nil
+}
+
+// AmendOrder changes the limit price or the quantity of an open order in
+// place, resizing its hold by the difference.
+func (s *TradingService) AmendOrder(ctx context.Context, orderID int, req *domain.AmendOrderRequest) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.AmendOrder")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	var order *domain.Order
+	err := s.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+		var err error
+		order, err = s.amendOrder(ctx, tx, orderID, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (s *TradingService) amendOrder(ctx context.Context, tx db.Querier, orderID int, req *domain.AmendOrderRequest) (*domain.Order, error) {
+	order, err := s.orderRepo.GetByID(ctx, tx, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrOrderNotFound
 		}
-		newBalance := account.Balance + refundAmount
-		if err := s.accountRepo.UpdateBalance(tx, order.AccountID, newBalance); err != nil {
+		return nil, err
+	}
+
+	if !order.Status.IsOpen() {
+		return nil, domain.ErrOrderNotAmendable
+	}
+	account, err := s.accountRepo.GetByID(ctx, tx, order.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Status != domain.AccountStatusActive {
+		return nil, domain.ErrAccountNotActive
+	}
+	if err := req.ValidateFor(order); err != nil {
+		return nil, err
+	}
+
+	price, quantity := order.Price, order.Quantity
+	if req.Price != nil {
+		price = *req.Price
+	}
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+	if price == order.Price && quantity == order.Quantity {
+		return order, nil
+	}
+
+	if err := s.resizeHold(ctx, tx, account, order, price, quantity); err != nil {
+		return nil, err
+	}
+
+	// Only a quantity decrease keeps the order's place in the queue; a new
+	// price or a larger quantity queues it behind the orders already there.
+	keepPriority := price == order.Price && quantity < order.Quantity
+	if err := s.orderRepo.Amend(ctx, tx, order.ID, order.Status, price, quantity, keepPriority); err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	if price != order.Price {
+		changes = append(changes, fmt.Sprintf("price %s -> %s", order.Price, price))
+	}
+	if quantity != order.Quantity {
+		changes = append(changes, fmt.Sprintf("quantity %d -> %d", order.Quantity, quantity))
+	}
+	oldStatus := order.Status
+	err = s.orderEventRepo.Create(ctx, tx, &domain.OrderEvent{
+		OrderID:           order.ID,
+		Type:              domain.OrderEventAmended,
+		OldStatus:         &oldStatus,
+		NewStatus:         order.Status,
+		OldQuantity:       order.Quantity,
+		NewQuantity:       quantity,
+		OldFilledQuantity: order.FilledQuantity,
+		NewFilledQuantity: order.FilledQuantity,
+		Actor:             domain.AccountActor(order.AccountID),
+		Reason:            strings.Join(changes, ", "),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A resting order with a new price may now cross the other side of the
+	// book, and its trades may trigger stop orders.
+	if price != order.Price && order.Status != domain.OrderStatusTriggerPending {
+		order.Price, order.Quantity = price, quantity
+		if err := s.executeOrder(ctx, tx, order); err != nil {
 			return nil, err
 		}
-	} else if order.Direction == "SELL" {
-		holding, err := s.holdingRepo.GetByAccountIDAndStockCode(tx, order.AccountID, order.StockCode)
-		if err != nil {
+		if err := s.triggerStops(ctx, tx, order.StockCode); err != nil {
 			return nil, err
 		}
-		
-		var newQuantity int
-		if holding == nil {
-			newQuantity = unfilledQuantity
-			newHolding := &domain.Holding{
-				AccountID: order.AccountID,
-				StockCode: order.StockCode,
-				Quantity:  newQuantity,
+	}
+
+	return s.orderRepo.GetByID(ctx, tx, order.ID)
+}
+
+// resizeHold grows or shrinks an order's hold to cover its unfilled quantity
+// at the amended price and quantity. Only growth needs free cash or shares;
+// a shrinking hold releases the difference.
+func (s *TradingService) resizeHold(ctx context.Context, tx db.Querier, account *domain.Account, order *domain.Order, price domain.Money, quantity int) error {
+	hold, err := s.holdRepo.GetByOrderID(ctx, tx, order.ID)
+	if err != nil {
+		return err
+	}
+	if hold == nil {
+		return fmt.Errorf("order %d has no hold", order.ID)
+	}
+
+	unfilled := quantity - order.FilledQuantity
+	if order.Direction == domain.DirectionBuy {
+		delta := price.MulInt(unfilled) - hold.RemainingAmount
+		if delta > 0 {
+			reserved, err := s.holdRepo.GetReservedCash(ctx, tx, order.AccountID)
+			if err != nil {
+				return err
 			}
-			if err := s.holdingRepo.Create(tx, newHolding); err != nil {
-				return nil, err
+			if account.Balance-reserved < delta {
+				return domain.ErrInsufficientFunds
 			}
-		} else {
-			newQuantity = holding.Quantity + unfilledQuantity
-			if err := s.holdingRepo.UpdateQuantity(tx, order.AccountID, order.StockCode, newQuantity); err != nil {
-				return nil, err
+		}
+		return s.holdRepo.Resize(ctx, tx, hold.ID, delta, 0)
+	}
+
+	delta := unfilled - hold.RemainingQuantity
+	if delta > 0 {
+		holding, err := s.holdingRepo.GetByAccountIDAndStockCode(ctx, tx, order.AccountID, order.StockCode)
+		if err != nil {
+			return err
+		}
+		reserved, err := s.holdRepo.GetReservedQuantity(ctx, tx, order.AccountID, order.StockCode)
+		if err != nil {
+			return err
+		}
+		if holding == nil || holding.Quantity-reserved < delta {
+			return domain.ErrInsufficientHoldingQuantity
+		}
+	}
+	return s.holdRepo.Resize(ctx, tx, hold.ID, 0, delta)
+}
+
+// ExpireOrders expires up to limit DAY and GTD orders whose expiry has
+// passed, each in a transaction of its own, and returns how many it expired.
+// Orders that were filled or canceled in the meantime, or that another
+// request is changing concurrently, are skipped.
+func (s *TradingService) ExpireOrders(ctx context.Context, limit int) (int, error) {
+	ctx, span := tracer.Start(ctx, "TradingService.ExpireOrders")
+	defer span.End()
+
+	ids, err := s.orderRepo.GetExpiredIDs(ctx, s.db, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		var ok bool
+		err := s.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+			var err error
+			ok, err = s.expireOrder(ctx, tx, id)
+			return err
+		})
+		if errors.Is(err, domain.ErrOrderStateConflict) {
+			// Another request changed the order concurrently; the next run
+			// looks at it again if it is still due.
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (s *TradingService) expireOrder(ctx context.Context, tx db.Querier, orderID int) (bool, error) {
+	order, err := s.orderRepo.GetByID(ctx, tx, orderID)
+	if err != nil {
+		return false, err
+	}
+	if !order.Status.IsOpen() || order.ExpiresAt == nil || order.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+
+	err = s.closeOrder(ctx, tx, order, domain.OrderStatusExpired, domain.OrderEventExpired,
+		domain.ActorSystem, fmt.Sprintf("%s order expired at %s", order.TimeInForce, order.ExpiresAt.Format(time.RFC3339)))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// dayOrderExpiry returns the next day order cutoff after now in the market
+// time zone.
+func (s *TradingService) dayOrderExpiry(now time.Time) time.Time {
+	local := now.In(s.marketTimezone)
+	cutoff := time.Date(local.Year(), local.Month(), local.Day(), s.dayOrderCutoff.Hour, s.dayOrderCutoff.Minute, 0, 0, s.marketTimezone)
+	if !cutoff.After(local) {
+		cutoff = cutoff.AddDate(0, 0, 1)
+	}
+	return cutoff
+}
+
+// executeOrder matches an order that just entered the book. Orders that do not
+// rest in the book, market orders and IOC and FOK orders, have whatever they
+// could not fill immediately canceled and its hold released.
+func (s *TradingService) executeOrder(ctx context.Context, tx db.Querier, order *domain.Order) error {
+	start := time.Now()
+	err := s.matchOrder(ctx, tx, order)
+	s.metrics.ObserveMatching(time.Since(start))
+	if err != nil {
+		return err
+	}
+
+	if order.RestsInBook() || order.Status == domain.OrderStatusFilled {
+		return nil
+	}
+
+	// A FOK order the book cannot fill in full is killed without a fill: it
+	// was rejected rather than canceled, although it ends CANCELED too.
+	if order.TimeInForce == domain.TimeInForceFOK {
+		reason := fmt.Sprintf("%s order could not be filled in full", order.TimeInForce)
+		return s.closeOrder(ctx, tx, order, domain.OrderStatusCanceled, domain.OrderEventRejected, domain.ActorMatchingEngine, reason)
+	}
+
+	reason := fmt.Sprintf("unfilled remainder of %s order canceled", order.TimeInForce)
+	if order.Type.IsMarket() {
+		reason = fmt.Sprintf("unfilled remainder of %s order canceled", order.Type)
+	}
+	return s.closeOrder(ctx, tx, order, domain.OrderStatusCanceled, domain.OrderEventCanceled, domain.ActorMatchingEngine, reason)
+}
+
+// triggerStops converts the stop orders of a stock code that the last trade
+// price has reached into market or limit orders and matches them. Their trades
+// move the last trade price in turn, so it repeats until no stop order is
+// triggered. Every round triggers at least one order, which never returns to
+// TRIGGER_PENDING, so the cascade ends.
+func (s *TradingService) triggerStops(ctx context.Context, tx db.Querier, stockCode string) error {
+	for {
+		lastPrice, err := s.tradeRepo.GetLastPrice(ctx, tx, stockCode)
+		if err != nil || lastPrice == nil {
+			return err
+		}
+
+		stops, err := s.orderRepo.GetTriggeredStops(ctx, tx, stockCode, *lastPrice)
+		if err != nil {
+			return err
+		}
+		if len(stops) == 0 {
+			return nil
+		}
+
+		for _, stop := range stops {
+			if err := s.triggerStop(ctx, tx, stop, *lastPrice); err != nil {
+				return err
 			}
 		}
 	}
+}
+
+// triggerStop moves a stop order into the book. Its hold was placed when the
+// order was created, at its limit or protection price, so triggering it never
+// fails for lack of cash or shares.
+func (s *TradingService) triggerStop(ctx context.Context, tx db.Querier, order *domain.Order, lastPrice domain.Money) error {
+	if err := s.orderRepo.Trigger(ctx, tx, order.ID, order.Status); err != nil {
+		return err
+	}
+
+	oldStatus := order.Status
+	err := s.orderEventRepo.Create(ctx, tx, &domain.OrderEvent{
+		OrderID:           order.ID,
+		Type:              domain.OrderEventTriggered,
+		OldStatus:         &oldStatus,
+		NewStatus:         domain.OrderStatusPending,
+		OldQuantity:       order.Quantity,
+		NewQuantity:       order.Quantity,
+		OldFilledQuantity: order.FilledQuantity,
+		NewFilledQuantity: order.FilledQuantity,
+		Actor:             domain.ActorMatchingEngine,
+		Reason:            fmt.Sprintf("last trade price %s reached stop price %s", lastPrice, order.StopPrice),
+	})
+	if err != nil {
+		return err
+	}
+
+	order.Status = domain.OrderStatusPending
+	return s.executeOrder(ctx, tx, order)
+}
+
+// closeOrder moves an open order to a final status without filling the rest
+// of it: it releases what remains of the order's hold, updates the status,
+// records the event and counts the order in orders_canceled_total.
+func (s *TradingService) closeOrder(ctx context.Context, tx db.Querier, order *domain.Order, status domain.OrderStatus, eventType, actor, reason string) error {
+	if err := s.releaseHold(ctx, tx, order.ID); err != nil {
+		return err
+	}
+
+	if err := s.orderRepo.UpdateStatus(ctx, tx, order.ID, order.Status, status); err != nil {
+		return err
+	}
+
+	oldStatus := order.Status
+	err := s.orderEventRepo.Create(ctx, tx, &domain.OrderEvent{
+		OrderID:           order.ID,
+		Type:              eventType,
+		OldStatus:         &oldStatus,
+		NewStatus:         status,
+		OldQuantity:       order.Quantity,
+		NewQuantity:       order.Quantity,
+		OldFilledQuantity: order.FilledQuantity,
+		NewFilledQuantity: order.FilledQuantity,
+		Actor:             actor,
+		Reason:            reason,
+	})
+	if err != nil {
+		return err
+	}
+	s.metrics.OrderCanceled()
+	return nil
+}
 
-	if err := s.orderRepo.UpdateStatus(tx, orderID, "CANCELED"); err != nil {
+// marketLimitPrice returns the worst price a MARKET or STOP order may trade
+// at: its protection price if it has one, otherwise a reference price moved
+// against the order by the configured collar. The reference is the stop price
+// for a STOP order and the best opposite price for a MARKET order. A buy is
+// reserved at this price. A MARKET order with no protection price and an
+// empty opposite side gets a limit of 0, so it crosses nothing and is
+// canceled right away.
+func (s *TradingService) marketLimitPrice(ctx context.Context, tx db.Querier, req *domain.CreateOrderRequest) (domain.Money, error) {
+	if req.ProtectionPrice != nil {
+		return *req.ProtectionPrice, nil
+	}
+
+	reference := req.StopPrice
+	if reference == nil {
+		best, err := s.orderRepo.GetBestPrice(ctx, tx, req.StockCode, req.Direction.Opposite())
+		if err != nil || best == nil {
+			return 0, err
+		}
+		reference = best
+	}
+
+	if req.Direction == domain.DirectionBuy {
+		return reference.MulRatio(10000+s.marketCollarBps, 10000), nil
+	}
+	return reference.MulRatio(10000-s.marketCollarBps, 10000), nil
+}
+
+// matchOrder crosses a freshly persisted order against the resting orders of
+// its stock code and settles every resulting execution in the same
+// transaction. Cash and shares for the order are held by CreateOrder, so
+// settlement only has to consume the holds and move ownership.
+func (s *TradingService) matchOrder(ctx context.Context, querier db.Querier, order *domain.Order) error {
+	ctx, span := tracer.Start(ctx, "TradingService.matchOrder")
+	defer span.End()
+
+	resting, err := s.orderRepo.GetCrossingOrders(ctx, querier, order.StockCode, order.Direction.Opposite(), order.Price)
+	if err != nil {
+		return err
+	}
+
+	// Every crossing order can trade with a FOK order in full, so it can be
+	// filled completely exactly when their remaining quantities cover it.
+	if order.TimeInForce == domain.TimeInForceFOK {
+		available := 0
+		for _, maker := range resting {
+			available += maker.Quantity - maker.FilledQuantity
+		}
+		if available < order.Quantity-order.FilledQuantity {
+			return nil
+		}
+	}
+
+	// Match updates the orders in place, so the statuses read from the
+	// database and the taker's filled quantity before each execution are kept
+	// here for the conditional updates and the per-fill events.
+	readStatus := make(map[int]domain.OrderStatus, len(resting)+1)
+	for _, maker := range resting {
+		readStatus[maker.ID] = maker.Status
+	}
+	readStatus[order.ID] = order.Status
+	takerFilled := order.FilledQuantity
+
+	book := matching.NewOrderBook(order.StockCode, resting)
+	for _, execution := range book.Match(order) {
+		trade, err := s.settle(ctx, querier, execution)
+		if err != nil {
+			return err
+		}
+		maker := execution.Maker
+		if err := s.orderRepo.UpdateFill(ctx, querier, maker.ID, maker.FilledQuantity, readStatus[maker.ID], maker.Status); err != nil {
+			return err
+		}
+
+		if err := s.recordFill(ctx, querier, maker, maker.FilledQuantity-execution.Quantity, maker.FilledQuantity, trade, execution.Taker.ID); err != nil {
+			return err
+		}
+		if err := s.recordFill(ctx, querier, order, takerFilled, takerFilled+execution.Quantity, trade, maker.ID); err != nil {
+			return err
+		}
+		takerFilled += execution.Quantity
+	}
+
+	if order.FilledQuantity > 0 {
+		return s.orderRepo.UpdateFill(ctx, querier, order.ID, order.FilledQuantity, readStatus[order.ID], order.Status)
+	}
+	return nil
+}
+
+// settle records the trade, moves the proceeds from the buyer to the seller
+// and the shares from the seller to the buyer, and consumes both orders'
+// holds for the filled quantity. The buyer's hold is consumed at its own limit
+// price, so any price improvement simply stays in the buyer's available cash.
+// The seller pays the configured sell-side fee out of the proceeds.
+// It returns the recorded trade.
+func (s *TradingService) settle(ctx context.Context, querier db.Querier, execution matching.Execution) (*domain.Trade, error) {
+	buyOrder, sellOrder := execution.Taker, execution.Maker
+	if buyOrder.Direction == domain.DirectionSell {
+		buyOrder, sellOrder = sellOrder, buyOrder
+	}
+
+	trade, err := s.tradeRepo.Create(ctx, querier, &domain.Trade{
+		StockCode:     execution.Taker.StockCode,
+		BuyOrderID:    buyOrder.ID,
+		SellOrderID:   sellOrder.ID,
+		BuyAccountID:  buyOrder.AccountID,
+		SellAccountID: sellOrder.AccountID,
+		Price:         execution.Price,
+		Quantity:      execution.Quantity,
+		AggressorSide: execution.Taker.Direction,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.consumeHold(ctx, querier, buyOrder.ID, buyOrder.Price.MulInt(execution.Quantity), 0); err != nil {
+		return nil, err
+	}
+	if err := s.consumeHold(ctx, querier, sellOrder.ID, 0, execution.Quantity); err != nil {
 		return nil, err
 	}
 
-	updatedOrder, err := s.orderRepo.GetByID(tx, orderID)
+	proceeds := execution.Price.MulInt(execution.Quantity)
+	err = s.postEntry(ctx, querier, domain.EntryTradeSettlement, nil, &trade.ID,
+		accountPosting(domain.LedgerCash, buyOrder.AccountID, -proceeds),
+		accountPosting(domain.LedgerCash, sellOrder.AccountID, proceeds),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
+	if fee := proceeds.MulRatio(s.sellFeeBps, 10000); fee > 0 {
+		err := s.postEntry(ctx, querier, domain.EntryFee, &sellOrder.ID, &trade.ID,
+			accountPosting(domain.LedgerCash, sellOrder.AccountID, -fee),
+			housePosting(domain.LedgerFeeRevenue, fee),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.debitHolding(ctx, querier, sellOrder.AccountID, sellOrder.StockCode, execution.Quantity); err != nil {
+		return nil, err
+	}
+	if err := s.creditHolding(ctx, querier, buyOrder.AccountID, buyOrder.StockCode, execution.Quantity); err != nil {
+		return nil, err
+	}
+	return trade, nil
+}
+
+// recordFill records that a trade took an order's filled quantity from
+// oldFilled to newFilled.
+func (s *TradingService) recordFill(ctx context.Context, querier db.Querier, order *domain.Order, oldFilled, newFilled int, trade *domain.Trade, counterpartyOrderID int) error {
+	oldStatus := domain.FillStatus(order.Quantity, oldFilled)
+	newStatus := domain.FillStatus(order.Quantity, newFilled)
+	eventType := domain.OrderEventPartiallyFilled
+	if newStatus == domain.OrderStatusFilled {
+		eventType = domain.OrderEventFilled
+	}
+
+	return s.orderEventRepo.Create(ctx, querier, &domain.OrderEvent{
+		OrderID:           order.ID,
+		Type:              eventType,
+		OldStatus:         &oldStatus,
+		NewStatus:         newStatus,
+		OldQuantity:       order.Quantity,
+		NewQuantity:       order.Quantity,
+		OldFilledQuantity: oldFilled,
+		NewFilledQuantity: newFilled,
+		TradeID:           &trade.ID,
+		Actor:             domain.ActorMatchingEngine,
+		Reason:            fmt.Sprintf("matched %d at %s with order %d", trade.Quantity, trade.Price, counterpartyOrderID),
+	})
+}
+
+// consumeHold reduces an order's hold by the cash or shares used by a fill and
+// releases it once nothing remains.
+func (s *TradingService) consumeHold(ctx context.Context, querier db.Querier, orderID int, amount domain.Money, quantity int) error {
+	hold, err := s.holdRepo.GetByOrderID(ctx, querier, orderID)
+	if err != nil || hold == nil {
+		return err
+	}
+
+	remainingAmount := hold.RemainingAmount - amount
+	remainingQuantity := hold.RemainingQuantity - quantity
+	status := hold.Status
+	if remainingAmount.IsZero() && remainingQuantity == 0 {
+		status = domain.HoldStatusReleased
+	}
+	return s.holdRepo.UpdateRemaining(ctx, querier, hold.ID, remainingAmount, remainingQuantity, status)
+}
+
+// releaseHold releases whatever remains of an order's hold.
+func (s *TradingService) releaseHold(ctx context.Context, querier db.Querier, orderID int) error {
+	hold, err := s.holdRepo.GetByOrderID(ctx, querier, orderID)
+	if err != nil || hold == nil || hold.Status == domain.HoldStatusReleased {
+		return err
+	}
+	return s.holdRepo.UpdateRemaining(ctx, querier, hold.ID, 0, 0, domain.HoldStatusReleased)
+}
+
+func (s *TradingService) debitHolding(ctx context.Context, querier db.Querier, accountID int, stockCode string, quantity int) error {
+	holding, err := s.holdingRepo.GetByAccountIDAndStockCode(ctx, querier, accountID, stockCode)
+	if err != nil {
+		return err
+	}
+	if holding == nil || holding.Quantity < quantity {
+		return domain.ErrInsufficientHoldingQuantity
+	}
+	return s.holdingRepo.UpdateQuantity(ctx, querier, accountID, stockCode, holding.Quantity-quantity)
+}
+
+func (s *TradingService) creditHolding(ctx context.Context, querier db.Querier, accountID int, stockCode string, quantity int) error {
+	holding, err := s.holdingRepo.GetByAccountIDAndStockCode(ctx, querier, accountID, stockCode)
+	if err != nil {
+		return err
+	}
+
+	if holding == nil {
+		return s.holdingRepo.Create(ctx, querier, &domain.Holding{
+			AccountID: accountID,
+			StockCode: stockCode,
+			Quantity:  quantity,
+		})
+	}
+	return s.holdingRepo.UpdateQuantity(ctx, querier, accountID, stockCode, holding.Quantity+quantity)
+}
+
+func hashRequest(req *domain.CreateOrderRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func replay(record *domain.IdempotencyRecord, requestHash string) (*domain.CreateOrderResult, error) {
+	if record.RequestHash != requestHash {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+
+	var order domain.Order
+	if err := json.Unmarshal(record.ResponseBody, &order); err != nil {
 		return nil, err
 	}
+	return &domain.CreateOrderResult{Order: &order, StatusCode: record.StatusCode, Replayed: true}, nil
+}
+
+// postEntry posts a balanced journal entry, leaving out zero-amount postings.
+func (s *TradingService) postEntry(ctx context.Context, querier db.Querier, entryType string, orderID *int, tradeID *int, postings ...*domain.Posting) error {
+	entry := &domain.JournalEntry{
+		Type:    entryType,
+		OrderID: orderID,
+		TradeID: tradeID,
+	}
+	for _, posting := range postings {
+		if !posting.Amount.IsZero() {
+			entry.Postings = append(entry.Postings, posting)
+		}
+	}
+	if len(entry.Postings) == 0 {
+		return nil
+	}
 
-	return updatedOrder, nil
-}
\ No newline at end of file
+	_, err := s.journalRepo.Post(ctx, querier, entry)
+	return err
+}
+
+func accountPosting(ledger string, accountID int, amount domain.Money) *domain.Posting {
+	return &domain.Posting{Ledger: ledger, AccountID: &accountID, Amount: amount}
+}
+
+func housePosting(ledger string, amount domain.Money) *domain.Posting {
+	return &domain.Posting{Ledger: ledger, Amount: amount}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"mini-ledger/internal/db"
+	"mini-ledger/internal/domain"
+	"mini-ledger/internal/repository"
+)
+
+// TransferService moves cash or shares between two accounts in one
+// transaction and keeps a record of every transfer.
+type TransferService struct {
+	db           *db.Database
+	accountRepo  repository.AccountRepository
+	holdingRepo  repository.HoldingRepository
+	holdRepo     repository.HoldRepository
+	journalRepo  repository.JournalRepository
+	transferRepo repository.TransferRepository
+}
+
+func NewTransferService(
+	database *db.Database,
+	accountRepo repository.AccountRepository,
+	holdingRepo repository.HoldingRepository,
+	holdRepo repository.HoldRepository,
+	journalRepo repository.JournalRepository,
+	transferRepo repository.TransferRepository,
+) *TransferService {
+	return &TransferService{
+		db:           database,
+		accountRepo:  accountRepo,
+		holdingRepo:  holdingRepo,
+		holdRepo:     holdRepo,
+		journalRepo:  journalRepo,
+		transferRepo: transferRepo,
+	}
+}
+
+// CreateTransfer moves available cash or shares out of an active account
+// into an account that is not closed. Both accounts are locked in id order
+// before anything is read, and the debit and credit are applied in the same
+// order, so concurrent transfers between the same accounts in opposite
+// directions queue up instead of deadlocking.
+func (s *TransferService) CreateTransfer(ctx context.Context, req *domain.CreateTransferRequest) (*domain.Transfer, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	var transfer *domain.Transfer
+	err := s.db.RunInTx(ctx, func(ctx context.Context, tx db.Querier) error {
+		accounts, err := s.accountRepo.GetForUpdate(ctx, tx, req.FromAccountID, req.ToAccountID)
+		if err != nil {
+			return err
+		}
+		if len(accounts) != 2 {
+			return domain.ErrAccountNotFound
+		}
+		from, to := accounts[0], accounts[1]
+		if from.ID != req.FromAccountID {
+			from, to = to, from
+		}
+		if from.Status != domain.AccountStatusActive || to.Status == domain.AccountStatusClosed {
+			return domain.ErrAccountNotActive
+		}
+
+		if req.Kind() == domain.TransferCash {
+			transfer, err = s.transferCash(ctx, tx, from, to, req)
+		} else {
+			transfer, err = s.transferSecurity(ctx, tx, accounts, req)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (s *TransferService) transferCash(ctx context.Context, tx db.Querier, from, to *domain.Account, req *domain.CreateTransferRequest) (*domain.Transfer, error) {
+	reserved, err := s.holdRepo.GetReservedCash(ctx, tx, from.ID)
+	if err != nil {
+		return nil, err
+	}
+	if from.Balance-reserved < req.Amount {
+		return nil, domain.ErrInsufficientFunds
+	}
+
+	transfer, err := s.transferRepo.Create(ctx, tx, &domain.Transfer{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Kind:          domain.TransferCash,
+		Amount:        req.Amount,
+		Memo:          strings.TrimSpace(req.Memo),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Postings update the balances in the order given, so they follow the
+	// account lock order.
+	postings := []*domain.Posting{
+		accountPosting(domain.LedgerCash, from.ID, -req.Amount),
+		accountPosting(domain.LedgerCash, to.ID, req.Amount),
+	}
+	if to.ID < from.ID {
+		postings[0], postings[1] = postings[1], postings[0]
+	}
+	_, err = s.journalRepo.Post(ctx, tx, &domain.JournalEntry{
+		Type:        domain.EntryTransfer,
+		TransferID:  &transfer.ID,
+		Description: transfer.Memo,
+		Postings:    postings,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// transferSecurity moves shares between the accounts, which are given in lock
+// order.
+func (s *TransferService) transferSecurity(ctx context.Context, tx db.Querier, accounts []*domain.Account, req *domain.CreateTransferRequest) (*domain.Transfer, error) {
+	holding, err := s.holdingRepo.GetByAccountIDAndStockCode(ctx, tx, req.FromAccountID, req.StockCode)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := s.holdRepo.GetReservedQuantity(ctx, tx, req.FromAccountID, req.StockCode)
+	if err != nil {
+		return nil, err
+	}
+	if holding == nil || holding.Quantity-reserved < req.Quantity {
+		return nil, domain.ErrInsufficientHoldingQuantity
+	}
+
+	transfer, err := s.transferRepo.Create(ctx, tx, &domain.Transfer{
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Kind:          domain.TransferSecurity,
+		StockCode:     req.StockCode,
+		Quantity:      req.Quantity,
+		Memo:          strings.TrimSpace(req.Memo),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, account := range accounts {
+		if account.ID == req.FromAccountID {
+			err = s.holdingRepo.UpdateQuantity(ctx, tx, account.ID, req.StockCode, holding.Quantity-req.Quantity)
+		} else {
+			err = s.holdingRepo.Create(ctx, tx, &domain.Holding{
+				AccountID: account.ID,
+				StockCode: req.StockCode,
+				Quantity:  req.Quantity,
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return transfer, nil
+}
+
+func (s *TransferService) GetTransfer(ctx context.Context, transferID int) (*domain.Transfer, error) {
+	transfer, err := s.transferRepo.GetByID(ctx, s.db, transferID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrTransferNotFound
+		}
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (s *TransferService) GetAccountTransfers(ctx context.Context, accountID int) ([]*domain.Transfer, error) {
+	_, err := s.accountRepo.GetByID(ctx, s.db, accountID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrAccountNotFound
+		}
+		return nil, err
+	}
+
+	return s.transferRepo.GetByAccountID(ctx, s.db, accountID)
+}
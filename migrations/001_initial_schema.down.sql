@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS holdings;
+DROP TABLE IF EXISTS accounts;
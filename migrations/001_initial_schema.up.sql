@@ -1,5 +1,4 @@
 -- CockroachDB compatible schema
-CREATE DATABASE IF NOT EXISTS mini_ledger;
 
 -- accounts 테이블
 CREATE TABLE IF NOT EXISTS accounts (
@@ -34,4 +33,4 @@ CREATE TABLE IF NOT EXISTS orders (
     status STRING NOT NULL,         -- 'PENDING', 'PARTIAL', 'FILLED', 'CANCELED'
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-);
\ No newline at end of file
+);
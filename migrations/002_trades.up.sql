@@ -0,0 +1,17 @@
+-- trades 테이블 (체결)
+CREATE TABLE IF NOT EXISTS trades (
+    id SERIAL PRIMARY KEY,
+    stock_code STRING NOT NULL,
+    buy_order_id INT NOT NULL REFERENCES orders(id),
+    sell_order_id INT NOT NULL REFERENCES orders(id),
+    buy_account_id INT NOT NULL REFERENCES accounts(id),
+    sell_account_id INT NOT NULL REFERENCES accounts(id),
+    price DECIMAL(15,2) NOT NULL,
+    quantity INT NOT NULL,
+    aggressor_side STRING NOT NULL, -- 'BUY' or 'SELL'
+    executed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    INDEX (buy_order_id),
+    INDEX (sell_order_id),
+    INDEX (buy_account_id, executed_at),
+    INDEX (sell_account_id, executed_at)
+);
@@ -0,0 +1,33 @@
+-- journal_entries 테이블 (분개, append-only)
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id SERIAL PRIMARY KEY,
+    type STRING NOT NULL,           -- 'OPENING_BALANCE', 'ORDER_RESERVATION', 'RESERVATION_RELEASE', 'TRADE_SETTLEMENT', 'FEE'
+    order_id INT REFERENCES orders(id),
+    trade_id INT REFERENCES trades(id),
+    description STRING NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- postings 테이블 (분개 항목, 항목 합계는 분개마다 0)
+CREATE TABLE IF NOT EXISTS postings (
+    id SERIAL PRIMARY KEY,
+    entry_id INT NOT NULL REFERENCES journal_entries(id),
+    ledger STRING NOT NULL,         -- 'CASH', 'ORDER_RESERVE', 'FEE_REVENUE', 'EXTERNAL'
+    account_id INT REFERENCES accounts(id),
+    amount DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    INDEX (entry_id),
+    INDEX (account_id, ledger)
+);
+
+-- 기존 잔액을 개시 분개로 이관 (accounts.balance = CASH 항목 합계)
+INSERT INTO journal_entries (id, type, description)
+SELECT 1, 'OPENING_BALANCE', 'opening balances'
+WHERE NOT EXISTS (SELECT 1 FROM journal_entries) AND EXISTS (SELECT 1 FROM accounts WHERE balance <> 0);
+
+INSERT INTO postings (entry_id, ledger, account_id, amount)
+SELECT 1, 'CASH', id, balance FROM accounts
+WHERE balance <> 0 AND NOT EXISTS (SELECT 1 FROM postings WHERE entry_id = 1)
+UNION ALL
+SELECT 1, 'EXTERNAL', NULL, -SUM(balance) FROM accounts
+HAVING SUM(balance) <> 0 AND NOT EXISTS (SELECT 1 FROM postings WHERE entry_id = 1);
@@ -0,0 +1,2 @@
+-- 예약은 hold 로만 남아 있으므로 hold 를 지우기 전에 미체결 주문을 확인할 것
+DROP TABLE IF EXISTS holds;
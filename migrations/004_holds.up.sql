@@ -0,0 +1,53 @@
+-- holds 테이블 (미체결 주문의 현금/주식 예약)
+CREATE TABLE IF NOT EXISTS holds (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL UNIQUE REFERENCES orders(id),
+    account_id INT NOT NULL REFERENCES accounts(id),
+    kind STRING NOT NULL,           -- 'CASH' or 'SECURITY'
+    stock_code STRING NOT NULL,
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    quantity INT NOT NULL DEFAULT 0,
+    remaining_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    remaining_quantity INT NOT NULL DEFAULT 0,
+    status STRING NOT NULL,         -- 'ACTIVE' or 'RELEASED'
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    INDEX (account_id, status)
+);
+
+-- 미체결 매도 주문: 차감했던 보유 수량을 되돌리고 hold 로 전환
+WITH converted AS (
+    INSERT INTO holds (order_id, account_id, kind, stock_code, quantity, remaining_quantity, status)
+    SELECT id, account_id, 'SECURITY', stock_code, quantity - filled_quantity, quantity - filled_quantity, 'ACTIVE'
+    FROM orders o
+    WHERE direction = 'SELL' AND status IN ('PENDING', 'PARTIAL')
+      AND NOT EXISTS (SELECT 1 FROM holds h WHERE h.order_id = o.id)
+    RETURNING account_id, stock_code, quantity
+)
+INSERT INTO holdings (account_id, stock_code, quantity)
+SELECT account_id, stock_code, SUM(quantity) FROM converted GROUP BY account_id, stock_code
+ON CONFLICT (account_id, stock_code) DO UPDATE SET quantity = holdings.quantity + EXCLUDED.quantity, updated_at = NOW();
+
+-- 미체결 매수 주문: ORDER_RESERVE 로 옮겼던 현금을 hold 로 전환
+INSERT INTO holds (order_id, account_id, kind, stock_code, amount, remaining_amount, status)
+SELECT id, account_id, 'CASH', stock_code, price * (quantity - filled_quantity), price * (quantity - filled_quantity), 'ACTIVE'
+FROM orders o
+WHERE direction = 'BUY' AND status IN ('PENDING', 'PARTIAL')
+  AND NOT EXISTS (SELECT 1 FROM holds h WHERE h.order_id = o.id);
+
+WITH reserves AS (
+    SELECT account_id, SUM(amount) AS amount FROM postings
+    WHERE ledger = 'ORDER_RESERVE' GROUP BY account_id HAVING SUM(amount) <> 0
+), entry AS (
+    INSERT INTO journal_entries (type, description)
+    SELECT 'RESERVATION_RELEASE', 'order reservations moved to holds' WHERE EXISTS (SELECT 1 FROM reserves)
+    RETURNING id
+)
+INSERT INTO postings (entry_id, ledger, account_id, amount)
+SELECT entry.id, 'ORDER_RESERVE', reserves.account_id, -reserves.amount FROM entry, reserves
+UNION ALL
+SELECT entry.id, 'CASH', reserves.account_id, reserves.amount FROM entry, reserves;
+
+UPDATE accounts SET balance = p.total, updated_at = NOW()
+FROM (SELECT account_id, SUM(amount) AS total FROM postings WHERE ledger = 'CASH' GROUP BY account_id) p
+WHERE p.account_id = accounts.id AND accounts.balance <> p.total;
@@ -0,0 +1,10 @@
+-- idempotency_keys 테이블 (주문 요청 멱등성 키)
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    account_id INT NOT NULL REFERENCES accounts(id),
+    idempotency_key STRING NOT NULL,
+    request_hash STRING NOT NULL,   -- SHA-256 of the request body
+    status_code INT NOT NULL,
+    response_body JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_id, idempotency_key)
+);
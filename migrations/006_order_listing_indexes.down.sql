@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS orders@orders_account_stock_created_idx;
+DROP INDEX IF EXISTS orders@orders_account_status_created_idx;
+DROP INDEX IF EXISTS orders@orders_account_created_idx;
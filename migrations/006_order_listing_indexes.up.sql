@@ -0,0 +1,9 @@
+-- 계좌별 주문 조회용 인덱스 (created_at, id 기준 keyset pagination)
+CREATE INDEX IF NOT EXISTS orders_account_created_idx
+    ON orders (account_id, created_at DESC, id DESC) STORING (stock_code, direction, status);
+
+CREATE INDEX IF NOT EXISTS orders_account_status_created_idx
+    ON orders (account_id, status, created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS orders_account_stock_created_idx
+    ON orders (account_id, stock_code, created_at DESC, id DESC);
@@ -0,0 +1,31 @@
+-- order_events 테이블 (주문 상태 변경 이력, append-only)
+CREATE TABLE IF NOT EXISTS order_events (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id),
+    type STRING NOT NULL,           -- 'CREATED', 'PARTIALLY_FILLED', 'FILLED', 'CANCELED', 'AMENDED', 'EXPIRED', 'REJECTED'
+    old_status STRING,              -- NULL for CREATED
+    new_status STRING NOT NULL,
+    old_quantity INT NOT NULL DEFAULT 0,
+    new_quantity INT NOT NULL,
+    old_filled_quantity INT NOT NULL DEFAULT 0,
+    new_filled_quantity INT NOT NULL DEFAULT 0,
+    trade_id INT REFERENCES trades(id),
+    actor STRING NOT NULL,          -- 'account:<id>', 'matching-engine', 'system'
+    reason STRING NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    INDEX (order_id, created_at)
+);
+
+-- 기존 주문의 이력 이관 (생성 시점과 현재 상태만 알 수 있음)
+INSERT INTO order_events (order_id, type, new_status, new_quantity, actor, reason, created_at)
+SELECT id, 'CREATED', 'PENDING', quantity, 'system', 'backfilled', created_at
+FROM orders o
+WHERE NOT EXISTS (SELECT 1 FROM order_events e WHERE e.order_id = o.id);
+
+INSERT INTO order_events (order_id, type, old_status, new_status, old_quantity, new_quantity, new_filled_quantity, actor, reason, created_at)
+SELECT id,
+       CASE status WHEN 'PARTIAL' THEN 'PARTIALLY_FILLED' ELSE status END,
+       'PENDING', status, quantity, quantity, filled_quantity, 'system', 'backfilled from order status', updated_at
+FROM orders o
+WHERE status IN ('PARTIAL', 'FILLED', 'CANCELED')
+  AND NOT EXISTS (SELECT 1 FROM order_events e WHERE e.order_id = o.id AND e.type <> 'CREATED');
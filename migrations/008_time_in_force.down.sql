@@ -0,0 +1,6 @@
+-- 만료 상태는 이전 버전에 없으므로 취소로 남김
+UPDATE orders SET status = 'CANCELED' WHERE status = 'EXPIRED';
+
+DROP INDEX IF EXISTS orders@orders_expiry_idx;
+ALTER TABLE orders DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS time_in_force;
@@ -0,0 +1,10 @@
+-- 주문 유효기간 (time in force)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS time_in_force STRING NOT NULL DEFAULT 'GTC';  -- 'DAY', 'GTC', 'IOC', 'FOK', 'GTD'
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;                       -- DAY, GTD 주문만
+
+-- 시장가 주문은 항상 즉시 체결 후 잔량 취소였음
+UPDATE orders SET time_in_force = 'IOC' WHERE type = 'MARKET' AND time_in_force = 'GTC';
+
+-- 만료 대상 미체결 주문 조회용
+CREATE INDEX IF NOT EXISTS orders_expiry_idx ON orders (expires_at)
+    WHERE expires_at IS NOT NULL AND status IN ('PENDING', 'PARTIAL');
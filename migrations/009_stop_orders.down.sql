@@ -0,0 +1,12 @@
+-- 발동 대기 주문은 이전 버전에서 처리할 수 없으므로 취소하고 hold 를 해제
+UPDATE holds SET remaining_amount = 0, remaining_quantity = 0, status = 'RELEASED', updated_at = NOW()
+WHERE status = 'ACTIVE' AND order_id IN (SELECT id FROM orders WHERE status = 'TRIGGER_PENDING');
+UPDATE orders SET status = 'CANCELED', updated_at = NOW() WHERE status = 'TRIGGER_PENDING';
+
+DROP INDEX IF EXISTS orders@orders_expiry_idx;
+CREATE INDEX IF NOT EXISTS orders_expiry_idx ON orders (expires_at)
+    WHERE expires_at IS NOT NULL AND status IN ('PENDING', 'PARTIAL');
+
+DROP INDEX IF EXISTS trades@trades_stock_executed_idx;
+DROP INDEX IF EXISTS orders@orders_stop_trigger_idx;
+ALTER TABLE orders DROP COLUMN IF EXISTS stop_price;
@@ -0,0 +1,14 @@
+-- 역지정가 주문 (STOP, STOP_LIMIT)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS stop_price DECIMAL(15,2);
+
+-- 체결가 도달 시 발동할 대기 주문 조회용
+CREATE INDEX IF NOT EXISTS orders_stop_trigger_idx ON orders (stock_code, stop_price)
+    WHERE status = 'TRIGGER_PENDING';
+
+-- 종목별 최근 체결가 조회용
+CREATE INDEX IF NOT EXISTS trades_stock_executed_idx ON trades (stock_code, executed_at DESC, id DESC);
+
+-- 발동 대기 주문도 만료 대상
+DROP INDEX IF EXISTS orders@orders_expiry_idx;
+CREATE INDEX IF NOT EXISTS orders_expiry_idx ON orders (expires_at)
+    WHERE expires_at IS NOT NULL AND status IN ('PENDING', 'PARTIAL', 'TRIGGER_PENDING');
@@ -0,0 +1,3 @@
+-- 주문 정정 시 시간 우선순위 (가격 변경, 수량 증가 시 갱신)
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS priority_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+UPDATE orders SET priority_at = created_at WHERE priority_at <> created_at;
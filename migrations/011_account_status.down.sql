@@ -0,0 +1,4 @@
+DROP SEQUENCE IF EXISTS account_number_seq;
+DROP INDEX IF EXISTS accounts@accounts_status_idx;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status;
+ALTER TABLE accounts DROP COLUMN IF EXISTS name;
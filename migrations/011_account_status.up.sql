@@ -0,0 +1,9 @@
+-- 계좌 명의 및 상태 (ACTIVE, FROZEN, CLOSED)
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS name STRING NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status STRING NOT NULL DEFAULT 'ACTIVE';
+
+-- 상태별 계좌 목록 조회용
+CREATE INDEX IF NOT EXISTS accounts_status_idx ON accounts (status, id);
+
+-- 계좌번호 발급용 시퀀스 (번호 뒤에 Luhn 검증 숫자를 붙임)
+CREATE SEQUENCE IF NOT EXISTS account_number_seq;
@@ -0,0 +1,6 @@
+-- 대기 중인 출금은 이전 버전에서 처리할 수 없으므로 hold 를 삭제
+DELETE FROM holds WHERE order_id IS NULL;
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS cash_movement_id;
+ALTER TABLE holds DROP COLUMN IF EXISTS cash_movement_id;
+ALTER TABLE holds ALTER COLUMN order_id SET NOT NULL;
+DROP TABLE IF EXISTS cash_movements;
@@ -0,0 +1,21 @@
+-- cash_movements 테이블 (입출금, 외부 참조번호 기준 멱등)
+CREATE TABLE IF NOT EXISTS cash_movements (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL REFERENCES accounts(id),
+    type STRING NOT NULL,           -- 'DEPOSIT' or 'WITHDRAWAL'
+    amount DECIMAL(15,2) NOT NULL,
+    external_reference STRING NOT NULL,
+    status STRING NOT NULL,         -- 'PENDING', 'COMPLETED', 'FAILED'
+    failure_reason STRING NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (account_id, type, external_reference),
+    INDEX (account_id, created_at DESC, id DESC)
+);
+
+-- 대기 중인 출금도 hold 로 현금을 예약
+ALTER TABLE holds ALTER COLUMN order_id DROP NOT NULL;
+ALTER TABLE holds ADD COLUMN IF NOT EXISTS cash_movement_id INT UNIQUE REFERENCES cash_movements(id);
+
+-- 입출금 분개와 원 거래 연결
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS cash_movement_id INT REFERENCES cash_movements(id);
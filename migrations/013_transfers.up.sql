@@ -0,0 +1,18 @@
+-- transfers 테이블 (계좌 간 현금/주식 대체 이력)
+CREATE TABLE IF NOT EXISTS transfers (
+    id SERIAL PRIMARY KEY,
+    from_account_id INT NOT NULL REFERENCES accounts(id),
+    to_account_id INT NOT NULL REFERENCES accounts(id),
+    kind STRING NOT NULL,           -- 'CASH' or 'SECURITY'
+    stock_code STRING NOT NULL DEFAULT '',
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    quantity INT NOT NULL DEFAULT 0,
+    memo STRING NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (from_account_id <> to_account_id),
+    INDEX (from_account_id, created_at DESC, id DESC),
+    INDEX (to_account_id, created_at DESC, id DESC)
+);
+
+-- 현금 대체 분개와 원 거래 연결
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS transfer_id INT REFERENCES transfers(id);
@@ -0,0 +1,16 @@
+-- api_keys 테이블 (키 원문은 저장하지 않고 SHA-256 해시만 저장)
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name STRING NOT NULL,
+    key_hash STRING NOT NULL UNIQUE,   -- hex(SHA-256(key))
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+-- API 키별 접근 가능 계좌
+CREATE TABLE IF NOT EXISTS api_key_accounts (
+    api_key_id INT NOT NULL REFERENCES api_keys(id),
+    account_id INT NOT NULL REFERENCES accounts(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (api_key_id, account_id)
+);
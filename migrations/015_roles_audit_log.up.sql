@@ -0,0 +1,16 @@
+-- API 키 역할 (CUSTOMER, TRADER, OPERATOR, AUDITOR, ADMIN). 기존 키는 TRADER
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS roles STRING[] NOT NULL DEFAULT ARRAY['TRADER'];
+
+-- 관리자 API 감사 로그 (요청한 주체와 결과를 기록)
+CREATE TABLE IF NOT EXISTS audit_log (
+    id SERIAL PRIMARY KEY,
+    actor STRING NOT NULL,             -- JWT subject 또는 'api-key:<name>'
+    api_key_id INT REFERENCES api_keys(id),
+    roles STRING[] NOT NULL,
+    method STRING NOT NULL,
+    path STRING NOT NULL,
+    status_code INT NOT NULL,
+    request_id STRING NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    INDEX (actor, id DESC)
+);
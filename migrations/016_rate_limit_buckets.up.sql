@@ -0,0 +1,8 @@
+-- 공유 모드(RATE_LIMIT_STORE=cockroach)의 토큰 버킷. 1시간 동안 쓰이지 않은 버킷은 TTL로 삭제
+-- (가득 찬 버킷과 없는 버킷은 같으므로 삭제해도 제한이 바뀌지 않음)
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key STRING PRIMARY KEY,            -- 예: 'order:key:1', 'read:account:2'
+    tokens FLOAT8 NOT NULL,
+    allowed BOOL NOT NULL,             -- 마지막 요청이 토큰을 받았는지
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+) WITH (ttl_expire_after = '1 hour');
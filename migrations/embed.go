@@ -0,0 +1,8 @@
+// Package migrations embeds the versioned schema migrations
+// (NNN_name.up.sql / NNN_name.down.sql) and the opt-in seed data (seed/*.sql).
+package migrations
+
+import "embed"
+
+//go:embed *.sql seed/*.sql
+var FS embed.FS
@@ -0,0 +1,24 @@
+-- 테스트 데이터
+INSERT INTO accounts (id, account_number, name, balance) VALUES (1, 'AC001', 'Test Account', 1000000) ON CONFLICT (id) DO NOTHING;
+INSERT INTO holdings (account_id, stock_code, quantity) VALUES (1, 'STOCK01', 100) ON CONFLICT (account_id, stock_code) DO NOTHING;
+INSERT INTO journal_entries (id, type, description) VALUES (1, 'OPENING_BALANCE', 'test data') ON CONFLICT (id) DO NOTHING;
+INSERT INTO postings (entry_id, ledger, account_id, amount)
+SELECT entry_id, ledger, account_id, amount FROM (VALUES
+    (1, 'EXTERNAL', NULL::INT, -1000000::DECIMAL),
+    (1, 'CASH', 1, 1000000)
+) AS v (entry_id, ledger, account_id, amount)
+WHERE NOT EXISTS (SELECT 1 FROM postings WHERE entry_id = 1);
+
+-- 테스트용 API 키 (mlk_test_account1_7f3c9a2e5b1d4f60: 계좌 1, mlk_test_noaccess_2b8e6d1f4a9c3e70: 계좌 없음)
+INSERT INTO api_keys (id, name, key_hash) VALUES
+    (1, 'test account 1', '09592973a7d648332181c0d0ec63fba2bd3626eef80f5f469e0aff3b49b8d7c6'),
+    (2, 'test no access', 'c5fd2faa0260e4e3b22cf6ef6190f537f823730fc41418518ed043636cfa0539')
+ON CONFLICT (id) DO NOTHING;
+INSERT INTO api_key_accounts (api_key_id, account_id) VALUES (1, 1) ON CONFLICT (api_key_id, account_id) DO NOTHING;
+
+-- 테스트용 관리자 API 키 (mlk_test_operator_5d2a8c7e1f3b9a64, mlk_test_auditor_8e4f1b6c3a7d2e95, mlk_test_admin_3c9e7a1d5f2b8c46)
+INSERT INTO api_keys (id, name, key_hash, roles) VALUES
+    (3, 'test operator', 'f730d02648764cdd624bed6b331a5848c4f413892d0236cae326ff15ed502a46', ARRAY['OPERATOR']),
+    (4, 'test auditor', 'b9001971dbeb39630ad6f97b965dbd52cf687b3d3b0fdbc3466c26868328bfa4', ARRAY['AUDITOR']),
+    (5, 'test admin', '279a2a7667b1268100dd4c2863b09eed304e4efe0f06546c942dc914bd024f56', ARRAY['ADMIN'])
+ON CONFLICT (id) DO NOTHING;